@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/ranakdinesh/spur/internal/configdocs"
 	"github.com/ranakdinesh/spur/internal/scaffold"
 )
 
@@ -24,6 +25,14 @@ func main() {
 		}
 		newService(args)
 
+	case "config":
+		args := os.Args[2:]
+		if len(args) == 0 || args[0] != "docs" {
+			usage()
+			os.Exit(2)
+		}
+		configDocs(args[1:])
+
 	default:
 		usage()
 		os.Exit(2)
@@ -31,10 +40,11 @@ func main() {
 }
 
 func usage() {
-	fmt.Println(`spur - Scaffolding CLI
+	fmt.Print(`spur - Scaffolding CLI
 Usage:
 	spur new service <name> [flags]
 	spur new <name> [flags] # also supported
+	spur config docs [flags]
 
 Flags:
 	--module            Go module path for the new service (required)
@@ -47,9 +57,18 @@ Flags:
 	--with-kafka        Include Kafka (kafkit) wiring
 	--with-auth         Include Auth (authkit) wiring
 
+Config docs flags:
+	--pkg               Package that calls config.Load (default ".")
+	--env               .env.example output (default ".env.example", "" to skip)
+	--md                Markdown output (default "CONFIG.md", "" to skip)
+	--k8s               Directory for configmap.yaml/secret.yaml (default "": skip)
+	--force             Overwrite existing configmap.yaml/secret.yaml in --k8s
+	--name              Service name for k8s manifests (default: current directory name)
+
 Examples:
 		spur new service accounts --module github.com/you/accounts --with-db --with-auth
   		spur new accounts --module github.com/you/accounts
+		spur config docs --pkg ./internal/app
 `)
 }
 
@@ -102,3 +121,28 @@ func newService(argv []string) {
 
 	fmt.Println("✅ created service:", name)
 }
+
+func configDocs(argv []string) {
+	fs := flag.NewFlagSet("config docs", flag.ExitOnError)
+	pkg := fs.String("pkg", ".", "")
+	envFile := fs.String("env", ".env.example", "")
+	md := fs.String("md", "CONFIG.md", "")
+	k8s := fs.String("k8s", "", "")
+	force := fs.Bool("force", false, "")
+	name := fs.String("name", "", "")
+	_ = fs.Parse(argv)
+
+	if err := configdocs.Generate(configdocs.Options{
+		Dir:      *pkg,
+		Name:     *name,
+		EnvFile:  *envFile,
+		Markdown: *md,
+		K8sDir:   *k8s,
+		Force:    *force,
+	}); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✅ generated config docs from", *pkg)
+}
@@ -1,85 +1,414 @@
 package config
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/ranakdinesh/spur/utils"
 )
 
-// Load reads .env if present, then environment variables, into out (pointer to struct).
-func Load(out any) error {
-	_ = godotenv.Load() // best-effort: only populates process env if .env exists
+// Options controls where LoadWithOptions reads values from.
+type Options struct {
+	// File is an optional YAML (.yaml/.yml) or JSON (.json) config file.
+	// Defaults to $CONFIG_FILE, from the environment or the .env files;
+	// empty means no file.
+	File string
 
+	// Dir is searched for the .env profile files (default "."). Handy in
+	// tests, which run from the package directory.
+	Dir string
+
+	// Args, if non-nil, are scanned for flags generated from the struct
+	// (HTTP_ADDR -> --http-addr), usually os.Args[1:]. Unknown flags are
+	// ignored. With nil Args no flags are read.
+	Args []string
+
+	// Sources are extra layers (e.g. a shared Redis hash) consulted after
+	// `default` tags and before the config file, lowest precedence first.
+	Sources []Source
+
+	// Passphrase for `encrypted:"true"` fields (see utils.EncryptString): the
+	// env var named KeyEnv (default CONFIG_KEY) or, if unset, the contents of
+	// KeyFile (default $CONFIG_KEY_FILE).
+	KeyEnv  string
+	KeyFile string
+}
+
+// Load reads config into out (pointer to struct) using the default Options.
+//
+// Values are resolved from ordered sources, lowest precedence first: `default`
+// tags, Options.Sources, the CONFIG_FILE (YAML or JSON), the .env profile files (.env,
+// .env.<APP_ENV>, .env.local, .env.<APP_ENV>.local), process environment, and
+// command-line flags (only with Options.Args). LoadOrigins also reports the
+// winning source of each field.
+//
+// Secrets can be mounted as files: a field tagged `file:"true"` holds a path
+// whose contents are the value, and any FOO that is unset falls back to
+// reading the file named by FOO_FILE. Fields tagged `encrypted:"true"` are
+// decrypted with utils.DecryptString; see Options.KeyEnv.
+//
+// Fields are checked against validation tags (see checkTags) and structs
+// implementing Validator are asked to validate themselves. All problems are
+// collected into a single *Error.
+//
+// Nested structs are walked to any depth. A struct field may carry an
+// `envPrefix:"PRIMARY_DB_"` tag, which is prepended to the env name of every
+// field below it. Embedded structs are promoted (they share the parent's
+// prefix), and pointer-to-struct fields are only allocated when at least one
+// of their fields is set by a source.
+func Load(out any) error { return LoadWithOptions(out, Options{}) }
+
+// LoadWithOptions is Load with explicit sources.
+func LoadWithOptions(out any, opts Options) error {
+	_, err := LoadOrigins(out, opts)
+	return err
+}
+
+// LoadOrigins is LoadWithOptions that also returns which source each field
+// came from. Origins are returned even when loading fails.
+func LoadOrigins(out any, opts Options) (Origins, error) {
 	v := reflect.ValueOf(out)
 	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
-		return errors.New("configx: out must be pointer to struct")
+		return nil, errors.New("configx: out must be pointer to struct")
 	}
-	v = v.Elem()
-	t := v.Type()
 
-	var errs []string
+	ld := &loader{keyEnv: opts.KeyEnv, keyFile: opts.KeyFile}
+	if ld.keyEnv == "" {
+		ld.keyEnv = "CONFIG_KEY"
+	}
+	dotenvs, err := readDotEnvs(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("configx: %w", err)
+	}
+	ld.sources = append(ld.sources, opts.Sources...)
+	file := opts.File
+	if file == "" {
+		file = configFile(dotenvs)
+	}
+	if file != "" {
+		fs, err := readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("configx: %w", err)
+		}
+		ld.sources = append(ld.sources, fs)
+	}
+	for _, d := range dotenvs {
+		ld.sources = append(ld.sources, d)
+	}
+	ld.sources = append(ld.sources, EnvSource())
+	if opts.Args != nil {
+		keys := map[string]bool{}
+		collectKeys(v.Elem().Type(), "", keys)
+		ld.sources = append(ld.sources, parseFlags(opts.Args, keys))
+	}
+
+	ld.loadStruct(v.Elem(), "", "")
+	ld.validate(v.Elem(), "", 0)
+	exportDotEnv(dotenvs)
+
+	if errs := append(ld.srcErrs, ld.errs...); len(errs) > 0 {
+		return ld.origins, &Error{Fields: errs}
+	}
+	return ld.origins, nil
+}
+
+// MustLoad is a convenience wrapper that panics on error.
+func MustLoad(out any) {
+	if err := Load(out); err != nil {
+		panic(err)
+	}
+}
+
+type loader struct {
+	sources []Source // lowest precedence first
+	broken  map[int]bool
+	srcErrs []FieldError // kept apart so loadStructPtr's rollback can't drop them
+	errs    []FieldError
+	origins Origins
+
+	keyEnv, keyFile string
+	key             *string // resolved passphrase, cached on first use
+}
+
+// lookup returns the value for key from the highest-precedence source that
+// has it. A source that errors is reported once and skipped from then on.
+func (ld *loader) lookup(key string) (string, string, bool) {
+	for i := len(ld.sources) - 1; i >= 0; i-- {
+		if ld.broken[i] {
+			continue
+		}
+		v, ok, err := ld.sources[i].Lookup(key)
+		if err != nil {
+			if ld.broken == nil {
+				ld.broken = map[int]bool{}
+			}
+			ld.broken[i] = true
+			ld.srcErrs = append(ld.srcErrs, FieldError{Field: sourceName(ld.sources[i]), Env: key, Reason: err.Error()})
+			continue
+		}
+		if ok {
+			return v, sourceName(ld.sources[i]), true
+		}
+	}
+	return "", "", false
+}
+
+// loadStruct fills the exported fields of v. prefix is prepended to every env
+// name; path is the dotted field path used in error messages. It reports
+// whether any field was found in a source (defaults don't count).
+func (ld *loader) loadStruct(v reflect.Value, prefix, path string) bool {
+	t := v.Type()
+	set := false
 
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
 		if f.PkgPath != "" { // unexported
 			continue
 		}
-		// Nested struct support (one level)
-		if f.Type.Kind() == reflect.Struct && f.Anonymous == false {
-			subPtr := v.Field(i).Addr().Interface()
-			if err := Load(subPtr); err != nil {
-				errs = append(errs, fmt.Sprintf("%s: %v", f.Name, err))
+		fv := v.Field(i)
+		name := f.Name
+		if path != "" {
+			name = path + "." + f.Name
+		}
+
+		// Nested structs: embedded ones keep the parent's prefix (promoted
+		// fields), named ones add their own envPrefix, if any.
+		if st, ok := structType(f.Type); ok {
+			childPrefix := prefix + f.Tag.Get("envPrefix")
+			childPath := name
+			if f.Anonymous {
+				childPath = path
+			}
+			nErrs := len(ld.errs)
+			if f.Type.Kind() == reflect.Pointer {
+				if ld.loadStructPtr(fv, st, childPrefix, childPath) {
+					set = true
+				}
+				fv = fv.Elem()
+			} else if ld.loadStruct(fv, childPrefix, childPath) {
+				set = true
+			}
+			// An embedded struct's Validate is promoted to the parent, so
+			// it runs there instead.
+			if fv.IsValid() && !f.Anonymous {
+				ld.validate(fv, childPath, nErrs)
 			}
 			continue
 		}
 
-		envName := f.Tag.Get("env")
-		if envName == "" {
-			// default to field name in upper snake: AppPort -> APP_PORT
-			envName = toEnvName(f.Name)
-		}
-		raw, ok := os.LookupEnv(envName)
+		envName := EnvName(f, prefix)
+		fromFile := f.Tag.Get("file") == "true"
+		raw, src, ok := ld.lookup(envName)
 		if !ok {
-			if def := f.Tag.Get("default"); def != "" {
-				raw = def
-				ok = true
+			// FOO_FILE convention for mounted secrets.
+			if raw, src, ok = ld.lookup(envName + "_FILE"); ok {
+				envName += "_FILE"
+				fromFile = true
 			}
 		}
+		if ok {
+			set = true
+		} else if def := f.Tag.Get("default"); def != "" {
+			raw, src = def, "default"
+			ok = true
+		}
 		req := f.Tag.Get("required") == "true"
 
 		if !ok {
 			if req {
-				errs = append(errs, fmt.Sprintf("missing required %q", envName))
+				ld.fail(f, name, envName, "", "missing required value")
+			} else if f.Tag.Get("nonempty") == "true" {
+				ld.fail(f, name, envName, "", "must not be empty")
 			}
 			continue
 		}
 
-		if err := setField(v.Field(i), raw, f.Tag.Get("split")); err != nil {
-			errs = append(errs, fmt.Sprintf("%s (%s): %v", f.Name, envName, err))
+		val, err := ld.reveal(f, raw, fromFile)
+		if err == nil {
+			err = setField(fv, val, f.Tag)
+		}
+		if err == nil {
+			err = checkTags(fv, f.Tag)
 		}
+		if err != nil {
+			ld.fail(f, name, envName, raw, err.Error())
+			continue
+		}
+		ld.origins = append(ld.origins, Origin{Field: name, Env: envName, Source: src})
 	}
+	return set
+}
 
-	if len(errs) > 0 {
-		return errors.New("configx: \n - " + strings.Join(errs, "\n - "))
+// reveal turns a resolved value into the text to parse: file-backed values
+// are read from disk (trailing newlines trimmed), encrypted ones decrypted.
+func (ld *loader) reveal(f reflect.StructField, raw string, fromFile bool) (string, error) {
+	if fromFile {
+		b, err := os.ReadFile(raw)
+		if err != nil {
+			return "", err
+		}
+		raw = strings.TrimRight(string(b), "\r\n")
 	}
-	return nil
+	if f.Tag.Get("encrypted") == "true" {
+		key, err := ld.passphrase()
+		if err != nil {
+			return "", err
+		}
+		pt, err := utils.DecryptString(strings.TrimSpace(raw), key)
+		if err != nil {
+			return "", fmt.Errorf("decrypt: %w", err)
+		}
+		raw = pt
+	}
+	return raw, nil
 }
 
-// MustLoad is a convenience wrapper that panics on error.
-func MustLoad(out any) {
-	if err := Load(out); err != nil {
-		panic(err)
+func (ld *loader) passphrase() (string, error) {
+	if ld.key != nil {
+		return *ld.key, nil
+	}
+	key, _, ok := ld.lookup(ld.keyEnv)
+	if !ok {
+		path := ld.keyFile
+		if path == "" {
+			path, _, _ = ld.lookup(ld.keyEnv + "_FILE")
+		}
+		if path == "" {
+			return "", fmt.Errorf("encrypted value but no passphrase (set %s or %s_FILE)", ld.keyEnv, ld.keyEnv)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase: %w", err)
+		}
+		key = strings.TrimRight(string(b), "\r\n")
+	}
+	ld.key = &key
+	return key, nil
+}
+
+// loadStructPtr handles *struct fields. An existing value is filled in place;
+// a nil pointer is only allocated when one of its fields is set, so optional
+// blocks (e.g. a replica DB) stay nil and their required fields are not
+// reported as missing.
+func (ld *loader) loadStructPtr(fv reflect.Value, st reflect.Type, prefix, path string) bool {
+	if !fv.IsNil() {
+		return ld.loadStruct(fv.Elem(), prefix, path)
+	}
+	nv := reflect.New(st)
+	nErrs, nOrigins := len(ld.errs), len(ld.origins)
+	if !ld.loadStruct(nv.Elem(), prefix, path) {
+		ld.errs, ld.origins = ld.errs[:nErrs], ld.origins[:nOrigins]
+		return false
 	}
+	fv.Set(nv)
+	return true
 }
 
-func setField(fv reflect.Value, raw string, split string) error {
+// collectKeys walks t the same way loadStruct does and records every env
+// name, noting which ones are bools (so "--flag" alone means true).
+func collectKeys(t reflect.Type, prefix string, keys map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if st, ok := structType(f.Type); ok {
+			collectKeys(st, prefix+f.Tag.Get("envPrefix"), keys)
+			continue
+		}
+		keys[EnvName(f, prefix)] = f.Type.Kind() == reflect.Bool
+	}
+}
+
+// EnvName is the env name of a leaf field: its `env` tag, or the field name in
+// upper snake case (AppPort -> APP_PORT), with prefix prepended.
+func EnvName(f reflect.StructField, prefix string) string {
+	name := f.Tag.Get("env")
+	if name == "" {
+		name = toEnvName(f.Name)
+	}
+	return prefix + name
+}
+
+// structType reports whether t is a struct or pointer to struct that Load
+// should descend into, and returns the struct type.
+func structType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || isLeaf(t) {
+		return nil, false
+	}
+	return t, true
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isLeaf reports whether a struct type is parsed from a single value rather
+// than walked field by field.
+func isLeaf(t reflect.Type) bool {
+	return t == timeType || t == urlType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setField parses raw into fv. Supported: strings, bools, (unsigned) ints,
+// floats, time.Duration, time.Time (`layout` tag, default RFC3339), url.URL,
+// anything implementing encoding.TextUnmarshaler (net.IP, netip.Prefix,
+// custom enums), pointers to those, slices split on `split` (default ","),
+// and maps written as "k1=v1,k2=v2".
+func setField(fv reflect.Value, raw string, tag reflect.StructTag) error {
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		layout := tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		ts, err := time.Parse(layout, raw)
+		if err != nil {
+			return fmt.Errorf("invalid time %q (layout %q)", raw, layout)
+		}
+		fv.Set(reflect.ValueOf(ts))
+		return nil
+	case urlType:
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid url %q", raw)
+		}
+		fv.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	if fv.Kind() != reflect.Pointer && fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		if err := fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", fv.Type(), raw, err)
+		}
+		return nil
+	}
+
 	switch fv.Kind() {
+	case reflect.Pointer:
+		nv := reflect.New(fv.Type().Elem())
+		if err := setField(nv.Elem(), raw, tag); err != nil {
+			return err
+		}
+		fv.Set(nv)
 	case reflect.String:
 		fv.SetString(raw)
 	case reflect.Bool:
@@ -89,43 +418,73 @@ func setField(fv reflect.Value, raw string, split string) error {
 		}
 		fv.SetBool(b)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		// Special-case time.Duration
-		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
-			d, err := time.ParseDuration(raw)
-			if err != nil {
-				return fmt.Errorf("invalid duration %q", raw)
-			}
-			fv.SetInt(int64(d))
-			return nil
-		}
 		i, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
 		if err != nil {
 			return fmt.Errorf("invalid int %q", raw)
 		}
 		fv.SetInt(i)
-	case reflect.Slice:
-		if fv.Type().Elem().Kind() != reflect.String {
-			return fmt.Errorf("unsupported slice element type %s", fv.Type().Elem().Kind())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid uint %q", raw)
 		}
-		sep := ","
-		if split != "" {
-			sep = split
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		fl, err := strconv.ParseFloat(raw, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid float %q", raw)
+		}
+		fv.SetFloat(fl)
+	case reflect.Slice:
+		parts := splitList(raw, tag.Get("split"))
+		out := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setField(out.Index(i), p, tag); err != nil {
+				return err
+			}
 		}
-		parts := strings.Split(raw, sep)
-		out := make([]string, 0, len(parts))
+		fv.Set(out)
+	case reflect.Map:
+		parts := splitList(raw, tag.Get("split"))
+		out := reflect.MakeMapWithSize(fv.Type(), len(parts))
 		for _, p := range parts {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				out = append(out, p)
+			k, val, ok := strings.Cut(p, "=")
+			if !ok {
+				return fmt.Errorf("invalid map entry %q (want key=value)", p)
 			}
+			kv := reflect.New(fv.Type().Key()).Elem()
+			if err := setField(kv, strings.TrimSpace(k), tag); err != nil {
+				return err
+			}
+			vv := reflect.New(fv.Type().Elem()).Elem()
+			if err := setField(vv, strings.TrimSpace(val), tag); err != nil {
+				return err
+			}
+			out.SetMapIndex(kv, vv)
 		}
-		fv.Set(reflect.ValueOf(out))
+		fv.Set(out)
 	default:
 		return fmt.Errorf("unsupported kind %s", fv.Kind())
 	}
 	return nil
 }
 
+// splitList splits raw on sep (default ","), trimming items and dropping empty ones.
+func splitList(raw, sep string) []string {
+	if sep == "" {
+		sep = ","
+	}
+	parts := strings.Split(raw, sep)
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 func toEnvName(field string) string {
 	var b strings.Builder
 	for i, r := range field {
@@ -0,0 +1,490 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ranakdinesh/spur/utils"
+)
+
+type layered struct {
+	Port    int    `default:"1"`
+	Name    string `default:"svc"`
+	Verbose bool
+}
+
+// unsetenv unsets key for the test, restoring it afterwards.
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+// unsetLoaded clears what a load exported from .env into the environment.
+func unsetLoaded(t *testing.T, keys ...string) {
+	t.Cleanup(func() {
+		exportedMu.Lock()
+		defer exportedMu.Unlock()
+		for _, k := range keys {
+			if _, ok := exported[k]; ok {
+				os.Unsetenv(k)
+				delete(exported, k)
+			}
+		}
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string // value in Options.Sources
+		file       string // value in the YAML file
+		dotenv     string // value in .env
+		env        string // value in the process environment
+		flag       string // value on the command line
+		wantPort   int
+		wantSource string // without the "(path)" of file and dotenv
+	}{
+		{name: "default", wantPort: 1, wantSource: "default"},
+		{name: "source over default", source: "2", wantPort: 2, wantSource: "remote"},
+		{name: "file over source", source: "2", file: "3", wantPort: 3, wantSource: "file"},
+		{name: "dotenv over file", file: "3", dotenv: "4", wantPort: 4, wantSource: "dotenv"},
+		{name: "env over dotenv", dotenv: "4", env: "5", wantPort: 5, wantSource: "env"},
+		{name: "flag over env", env: "5", flag: "6", wantPort: 6, wantSource: "flag"},
+		{name: "flag over everything", source: "2", file: "3", dotenv: "4", env: "5", flag: "6", wantPort: 6, wantSource: "flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			unsetLoaded(t, "PORT")
+			opts := Options{Dir: dir, Args: []string{}}
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv("APP_ENV", "")
+			unsetenv(t, "PORT")
+			if tt.source != "" {
+				opts.Sources = []Source{MapSource("remote", map[string]string{"PORT": tt.source})}
+			}
+			if tt.file != "" {
+				opts.File = filepath.Join(dir, "config.yaml")
+				writeFile(t, opts.File, "port: "+tt.file+"\n")
+			}
+			if tt.dotenv != "" {
+				writeFile(t, filepath.Join(dir, ".env"), "PORT="+tt.dotenv+"\n")
+			}
+			if tt.env != "" {
+				t.Setenv("PORT", tt.env)
+			}
+			if tt.flag != "" {
+				opts.Args = []string{"--port", tt.flag}
+			}
+
+			var cfg layered
+			origins, err := LoadOrigins(&cfg, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.wantPort {
+				t.Errorf("Port = %d, want %d", cfg.Port, tt.wantPort)
+			}
+			got := originOf(origins, "Port")
+			if kind, _, _ := strings.Cut(got.Source, "("); kind != tt.wantSource {
+				t.Errorf("Port source = %q, want %q", got.Source, tt.wantSource)
+			}
+			if got.Env != "PORT" {
+				t.Errorf("Port env = %q, want PORT", got.Env)
+			}
+		})
+	}
+}
+
+func TestLoadFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantPort    int
+		wantVerbose bool
+		wantName    string
+	}{
+		{name: "nil args read no flags", args: nil, wantPort: 1, wantName: "svc"},
+		{name: "separate value", args: []string{"--port", "8080"}, wantPort: 8080, wantName: "svc"},
+		{name: "inline value", args: []string{"-port=8080", "--name=api"}, wantPort: 8080, wantName: "api"},
+		{name: "bare bool", args: []string{"--verbose"}, wantPort: 1, wantVerbose: true, wantName: "svc"},
+		{name: "unknown flags ignored", args: []string{"--other", "x", "--port=2"}, wantPort: 2, wantName: "svc"},
+		{name: "stop at --", args: []string{"--", "--port=2"}, wantPort: 1, wantName: "svc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			unsetenv(t, "PORT")
+			var cfg layered
+			if err := LoadWithOptions(&cfg, Options{Dir: t.TempDir(), Args: tt.args}); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.wantPort || cfg.Verbose != tt.wantVerbose || cfg.Name != tt.wantName {
+				t.Errorf("got %+v, want Port=%d Verbose=%v Name=%q", cfg, tt.wantPort, tt.wantVerbose, tt.wantName)
+			}
+		})
+	}
+}
+
+type nestedDB struct {
+	Host string `default:"localhost"`
+	Port int    `default:"5432"`
+}
+
+type nested struct {
+	Primary nestedDB  `envPrefix:"PRIMARY_DB_"`
+	Replica *nestedDB `envPrefix:"REPLICA_DB_"`
+}
+
+func TestLoadOriginsNested(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	src := MapSource("remote", map[string]string{"PRIMARY_DB_HOST": "db"})
+	var cfg nested
+	origins, err := LoadOrigins(&cfg, Options{Dir: t.TempDir(), Sources: []Source{src}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Origins{
+		{Field: "Primary.Host", Env: "PRIMARY_DB_HOST", Source: "remote"},
+		{Field: "Primary.Port", Env: "PRIMARY_DB_PORT", Source: "default"},
+	}
+	if len(origins) != len(want) {
+		t.Fatalf("origins = %+v, want %+v (unset pointer structs leave none)", origins, want)
+	}
+	for i := range want {
+		if origins[i] != want[i] {
+			t.Errorf("origins[%d] = %+v, want %+v", i, origins[i], want[i])
+		}
+	}
+	if cfg.Replica != nil {
+		t.Errorf("Replica = %+v, want nil", cfg.Replica)
+	}
+}
+
+func TestLoadOriginsPerLoad(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	unsetenv(t, "PORT")
+	// The same pointer loaded twice must not mix up the two results.
+	var cfg layered
+	first, err := LoadOrigins(&cfg, Options{Dir: t.TempDir(), Sources: []Source{MapSource("a", map[string]string{"PORT": "2"})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadOrigins(&cfg, Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := originOf(first, "Port").Source; got != "a" {
+		t.Errorf("first load: Port source = %q, want a", got)
+	}
+	if got := originOf(second, "Port").Source; got != "default" {
+		t.Errorf("second load: Port source = %q, want default", got)
+	}
+}
+
+func TestOriginsExplain(t *testing.T) {
+	out := Origins{{Field: "Port", Env: "PORT", Source: "env"}}.Explain()
+	want := "FIELD  ENV   SOURCE\nPort   PORT  env\n"
+	if out != want {
+		t.Errorf("Explain() = %q, want %q", out, want)
+	}
+}
+
+func originOf(origins Origins, field string) Origin {
+	for _, o := range origins {
+		if o.Field == field {
+			return o
+		}
+	}
+	return Origin{}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadFrom loads out from values alone (plus defaults).
+func loadFrom(t *testing.T, out any, values map[string]string) error {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	return LoadWithOptions(out, Options{Dir: t.TempDir(), Sources: []Source{MapSource("test", values)}})
+}
+
+type tlsBlock struct {
+	CertFile string
+}
+
+type dbBlock struct {
+	Host string   `required:"true"`
+	Port int      `default:"5432"`
+	TLS  tlsBlock `envPrefix:"TLS_"`
+}
+
+type Shared struct {
+	ServiceName string `default:"svc"`
+}
+
+type deep struct {
+	Shared
+	Other   Shared   `envPrefix:"NX_"`
+	Primary dbBlock  `envPrefix:"NX_PRIMARY_"`
+	Replica *dbBlock `envPrefix:"NX_REPLICA_"`
+}
+
+func TestLoadNested(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    deep
+		wantErr []string // env names of the failing fields
+	}{
+		{
+			name:   "prefixes compose",
+			values: map[string]string{"NX_PRIMARY_HOST": "db1", "NX_PRIMARY_TLS_CERT_FILE": "/c.pem", "NX_SERVICE_NAME": "x", "SERVICE_NAME": "top"},
+			want:   deep{Shared: Shared{ServiceName: "top"}, Other: Shared{ServiceName: "x"}, Primary: dbBlock{Host: "db1", Port: 5432, TLS: tlsBlock{CertFile: "/c.pem"}}},
+		},
+		{
+			name:   "unset pointer stays nil",
+			values: map[string]string{"NX_PRIMARY_HOST": "db1"},
+			want:   deep{Shared: Shared{ServiceName: "svc"}, Other: Shared{ServiceName: "svc"}, Primary: dbBlock{Host: "db1", Port: 5432}},
+		},
+		{
+			name:   "pointer allocated when a field is set",
+			values: map[string]string{"NX_PRIMARY_HOST": "db1", "NX_REPLICA_HOST": "db2"},
+			want: deep{
+				Shared: Shared{ServiceName: "svc"}, Other: Shared{ServiceName: "svc"},
+				Primary: dbBlock{Host: "db1", Port: 5432},
+				Replica: &dbBlock{Host: "db2", Port: 5432},
+			},
+		},
+		{
+			name:    "required inside a set pointer",
+			values:  map[string]string{"NX_PRIMARY_HOST": "db1", "NX_REPLICA_PORT": "6432"},
+			wantErr: []string{"NX_REPLICA_HOST"},
+		},
+		{
+			name:    "required in a named struct",
+			values:  map[string]string{},
+			wantErr: []string{"NX_PRIMARY_HOST"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg deep
+			err := loadFrom(t, &cfg, tt.values)
+			if len(tt.wantErr) > 0 {
+				var ce *Error
+				if !errors.As(err, &ce) {
+					t.Fatalf("err = %v, want *Error", err)
+				}
+				var envs []string
+				for _, f := range ce.Fields {
+					envs = append(envs, f.Env)
+				}
+				if !slices.Equal(envs, tt.wantErr) {
+					t.Errorf("failing fields = %v, want %v", envs, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, tt.want) {
+				t.Errorf("got %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+// level is a custom enum parsed through encoding.TextUnmarshaler.
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level")
+	}
+	return nil
+}
+
+type typed struct {
+	S      string
+	B      bool
+	I8     int8
+	U16    uint16
+	F      float64
+	D      time.Duration
+	T      time.Time
+	TDate  time.Time `layout:"2006-01-02"`
+	URL    url.URL
+	IP     net.IP
+	Prefix netip.Prefix
+	Level  level
+	PInt   *int
+	PLevel *level
+	List   []int
+	Piped  []string `split:"|"`
+	Levels []level
+	Map    map[string]time.Duration
+}
+
+func TestLoadTypes(t *testing.T) {
+	seven := 7
+	high := level(2)
+	tests := []struct {
+		name    string
+		env     string
+		raw     string
+		check   func(c typed) bool
+		wantErr string
+	}{
+		{name: "string", env: "S", raw: "hello", check: func(c typed) bool { return c.S == "hello" }},
+		{name: "bool", env: "B", raw: "true", check: func(c typed) bool { return c.B }},
+		{name: "int8", env: "I8", raw: "-12", check: func(c typed) bool { return c.I8 == -12 }},
+		{name: "int8 overflow", env: "I8", raw: "200", wantErr: "invalid int"},
+		{name: "uint16", env: "U16", raw: "65535", check: func(c typed) bool { return c.U16 == 65535 }},
+		{name: "uint negative", env: "U16", raw: "-1", wantErr: "invalid uint"},
+		{name: "float", env: "F", raw: "0.25", check: func(c typed) bool { return c.F == 0.25 }},
+		{name: "duration", env: "D", raw: "1m30s", check: func(c typed) bool { return c.D == 90*time.Second }},
+		{name: "bad duration", env: "D", raw: "90", wantErr: "invalid duration"},
+		{name: "time", env: "T", raw: "2024-05-01T12:00:00Z", check: func(c typed) bool { return c.T.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) }},
+		{name: "time with layout", env: "T_DATE", raw: "2024-05-01", check: func(c typed) bool { return c.TDate.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) }},
+		{name: "time wrong layout", env: "T_DATE", raw: "01/05/2024", wantErr: "invalid time"},
+		{name: "url", env: "URL", raw: "https://api.example.com/v1", check: func(c typed) bool { return c.URL.Host == "api.example.com" && c.URL.Path == "/v1" }},
+		{name: "ip via TextUnmarshaler", env: "IP", raw: "10.0.0.1", check: func(c typed) bool { return c.IP.Equal(net.IPv4(10, 0, 0, 1)) }},
+		{name: "bad ip", env: "IP", raw: "10.0.0", wantErr: "invalid net.IP"},
+		{name: "prefix", env: "PREFIX", raw: "10.0.0.0/8", check: func(c typed) bool { return c.Prefix == netip.MustParsePrefix("10.0.0.0/8") }},
+		{name: "custom enum", env: "LEVEL", raw: "high", check: func(c typed) bool { return c.Level == 2 }},
+		{name: "custom enum error", env: "LEVEL", raw: "mid", wantErr: "unknown level"},
+		{name: "pointer", env: "P_INT", raw: "7", check: func(c typed) bool { return c.PInt != nil && *c.PInt == seven }},
+		{name: "pointer to enum", env: "P_LEVEL", raw: "high", check: func(c typed) bool { return c.PLevel != nil && *c.PLevel == high }},
+		{name: "slice", env: "LIST", raw: "1, 2,,3", check: func(c typed) bool { return slices.Equal(c.List, []int{1, 2, 3}) }},
+		{name: "slice element error", env: "LIST", raw: "1,x", wantErr: "invalid int"},
+		{name: "slice with split", env: "PIPED", raw: "a,b|c", check: func(c typed) bool { return slices.Equal(c.Piped, []string{"a,b", "c"}) }},
+		{name: "slice of enums", env: "LEVELS", raw: "low,high", check: func(c typed) bool { return slices.Equal(c.Levels, []level{1, 2}) }},
+		{name: "map", env: "MAP", raw: "read=1s, write = 2s", check: func(c typed) bool {
+			return maps.Equal(c.Map, map[string]time.Duration{"read": time.Second, "write": 2 * time.Second})
+		}},
+		{name: "map entry without =", env: "MAP", raw: "read", wantErr: "invalid map entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg typed
+			err := loadFrom(t, &cfg, map[string]string{tt.env: tt.raw})
+			if tt.wantErr != "" {
+				var ce *Error
+				if !errors.As(err, &ce) || len(ce.Fields) != 1 || ce.Fields[0].Env != tt.env || !strings.Contains(ce.Fields[0].Reason, tt.wantErr) {
+					t.Errorf("err = %v, want %s: %s", err, tt.env, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("%s=%q loaded as %+v", tt.env, tt.raw, cfg)
+			}
+		})
+	}
+}
+
+func TestToEnvName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{in: "Port", want: "PORT"},
+		{in: "AppPort", want: "APP_PORT"},
+		{in: "APIToken", want: "API_TOKEN"},
+		{in: "HTTPAddr", want: "HTTP_ADDR"},
+		{in: "DBURL", want: "DBURL"},
+		{in: "TDate", want: "T_DATE"},
+	}
+	for _, tt := range tests {
+		if got := toEnvName(tt.in); got != tt.want {
+			t.Errorf("toEnvName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+type secrets struct {
+	DBPassword string
+	APIKey     string `file:"true"`
+	Token      string `encrypted:"true"`
+}
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	pwFile, keyFile, passFile := filepath.Join(dir, "pw"), filepath.Join(dir, "api-key"), filepath.Join(dir, "passphrase")
+	writeFile(t, pwFile, "s3cret\n")
+	writeFile(t, keyFile, "k-123\r\n")
+	writeFile(t, passFile, "open sesame\n")
+	sealed, err := utils.EncryptString("tok", "open sesame")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		opts    Options
+		want    secrets
+		wantErr string // reason of the single failing field
+		wantVal string // value reported with it
+	}{
+		{name: "FOO_FILE fallback", values: map[string]string{"DB_PASSWORD_FILE": pwFile}, want: secrets{DBPassword: "s3cret"}},
+		{name: "direct value wins over FOO_FILE", values: map[string]string{"DB_PASSWORD": "direct", "DB_PASSWORD_FILE": pwFile}, want: secrets{DBPassword: "direct"}},
+		{name: "file tag", values: map[string]string{"API_KEY": keyFile}, want: secrets{APIKey: "k-123"}},
+		{name: "missing file", values: map[string]string{"DB_PASSWORD_FILE": filepath.Join(dir, "nope")}, wantErr: "no such file", wantVal: "****"},
+		{name: "encrypted with CONFIG_KEY", values: map[string]string{"TOKEN": sealed, "CONFIG_KEY": "open sesame"}, want: secrets{Token: "tok"}},
+		{name: "encrypted with CONFIG_KEY_FILE", values: map[string]string{"TOKEN": sealed, "CONFIG_KEY_FILE": passFile}, want: secrets{Token: "tok"}},
+		{name: "encrypted with KeyEnv", values: map[string]string{"TOKEN": sealed, "APP_KEY": "open sesame"}, opts: Options{KeyEnv: "APP_KEY"}, want: secrets{Token: "tok"}},
+		{name: "encrypted with KeyFile", values: map[string]string{"TOKEN": sealed}, opts: Options{KeyFile: passFile}, want: secrets{Token: "tok"}},
+		{name: "wrong passphrase", values: map[string]string{"TOKEN": sealed, "CONFIG_KEY": "guess"}, wantErr: "decrypt", wantVal: "****"},
+		{name: "no passphrase", values: map[string]string{"TOKEN": sealed}, wantErr: "no passphrase", wantVal: "****"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			for _, k := range []string{"CONFIG_KEY", "CONFIG_KEY_FILE", "DB_PASSWORD", "DB_PASSWORD_FILE", "API_KEY", "TOKEN"} {
+				unsetenv(t, k)
+			}
+			opts := tt.opts
+			opts.Dir = t.TempDir()
+			opts.Sources = []Source{MapSource("test", tt.values)}
+			var cfg secrets
+			err := LoadWithOptions(&cfg, opts)
+			if tt.wantErr != "" {
+				var ce *Error
+				if !errors.As(err, &ce) || len(ce.Fields) != 1 || !strings.Contains(ce.Fields[0].Reason, tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if ce.Fields[0].Value != tt.wantVal {
+					t.Errorf("reported value %q, want %q", ce.Fields[0].Value, tt.wantVal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg != tt.want {
+				t.Errorf("got %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
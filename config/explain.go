@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Origin records which source a loaded field came from.
+type Origin struct {
+	Field  string // dotted Go path, e.g. "Primary.Host"
+	Env    string // resolved env name, e.g. "PRIMARY_DB_HOST"
+	Source string // "default", "file(path)", "dotenv(path)", "env", "flag" or a custom Source's name
+}
+
+// Origins is the provenance of one load, in field order.
+type Origins []Origin
+
+// Explain renders a table of every loaded field and where its value came from.
+func (o Origins) Explain() string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tENV\tSOURCE")
+	for _, x := range o {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", x.Field, x.Env, x.Source)
+	}
+	_ = tw.Flush()
+	return b.String()
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Source is one layer of raw config values keyed by env name. Lookup
+// reports whether the key is present; an error fails the load.
+//
+// The name shown in Explain is the source's String method, if it has one.
+type Source interface {
+	Lookup(key string) (string, bool, error)
+}
+
+// WatchableSource is a Source that can tell Watch when its values change.
+// Watch should block until ctx is done, calling changed after each change.
+type WatchableSource interface {
+	Source
+	Watch(ctx context.Context, changed func()) error
+}
+
+// EnvSource reads the process environment.
+func EnvSource() Source { return envSource{} }
+
+// FileSource reads a YAML or JSON file once; see Load for how keys are mapped.
+func FileSource(path string) (Source, error) { return readFile(path) }
+
+// MapSource serves fixed values, e.g. in tests.
+func MapSource(name string, values map[string]string) Source {
+	return mapSource{label: name, values: values}
+}
+
+func sourceName(s Source) string {
+	if st, ok := s.(fmt.Stringer); ok {
+		return st.String()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// mapSource serves values from a fixed map (file, .env, flags).
+type mapSource struct {
+	label  string
+	values map[string]string
+}
+
+func (m mapSource) String() string { return m.label }
+func (m mapSource) Lookup(key string) (string, bool, error) {
+	v, ok := m.values[key]
+	return v, ok, nil
+}
+
+// envSource reads the process environment. Values that Load itself exported
+// from .env are skipped, so their provenance stays "dotenv" on later loads.
+type envSource struct{}
+
+func (envSource) String() string { return "env" }
+func (envSource) Lookup(key string) (string, bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return "", false, nil
+	}
+	exportedMu.Lock()
+	ev, mine := exported[key]
+	exportedMu.Unlock()
+	if mine && ev == v {
+		return "", false, nil
+	}
+	return v, true, nil
+}
+
+var (
+	exportedMu sync.Mutex
+	exported   = map[string]string{}
+)
+
+// dotEnvFiles lists the .env profile files in dir, lowest precedence first.
+// appEnv is APP_ENV; without it only .env and .env.local apply.
+func dotEnvFiles(dir, appEnv string) []string {
+	dir = orDot(dir)
+	names := []string{".env"}
+	if appEnv != "" {
+		names = append(names, ".env."+appEnv)
+	}
+	names = append(names, ".env.local")
+	if appEnv != "" {
+		names = append(names, ".env."+appEnv+".local")
+	}
+	for i, n := range names {
+		names[i] = filepath.Join(dir, n)
+	}
+	return names
+}
+
+// readDotEnvs reads the .env profile files in dir without touching the
+// process environment. APP_ENV comes from the real environment or, failing
+// that, from .env itself. Missing files are skipped; malformed ones are errors.
+func readDotEnvs(dir string) ([]mapSource, error) {
+	base, err := readDotEnv(dotEnvFiles(dir, "")[0])
+	if err != nil {
+		return nil, err
+	}
+	appEnv, ok, _ := envSource{}.Lookup("APP_ENV")
+	if !ok {
+		appEnv = base.values["APP_ENV"]
+	}
+	out := []mapSource{base}
+	for _, path := range dotEnvFiles(dir, appEnv)[1:] {
+		src, err := readDotEnv(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(src.values) > 0 {
+			out = append(out, src)
+		}
+	}
+	return out, nil
+}
+
+// configFile is CONFIG_FILE from the real environment or, failing that,
+// from the .env profile files (later files win). Reading the files
+// directly makes the first load use the same file as later ones, which
+// find it exported by exportDotEnv.
+func configFile(dotenvs []mapSource) string {
+	if f, ok, _ := (envSource{}).Lookup("CONFIG_FILE"); ok {
+		return f
+	}
+	for i := len(dotenvs) - 1; i >= 0; i-- {
+		if f, ok := dotenvs[i].values["CONFIG_FILE"]; ok {
+			return f
+		}
+	}
+	return ""
+}
+
+func orDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+func readDotEnv(path string) (mapSource, error) {
+	src := mapSource{label: "dotenv(" + path + ")", values: map[string]string{}}
+	m, err := godotenv.Read(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return src, nil
+		}
+		return src, fmt.Errorf("read %s: %w", path, err)
+	}
+	src.values = m
+	return src, nil
+}
+
+// exportDotEnv copies .env values into the process environment (without
+// overriding real env vars) for code that still reads os.Getenv directly,
+// e.g. LOG_LEVEL in logger or OTEL_* in otelx. Later profile files win.
+func exportDotEnv(srcs []mapSource) {
+	exportedMu.Lock()
+	defer exportedMu.Unlock()
+	for _, s := range srcs {
+		for k, v := range s.values {
+			// Real env vars win, even empty ones.
+			if cur, ok := os.LookupEnv(k); ok {
+				if ev, mine := exported[k]; !mine || ev != cur {
+					continue
+				}
+			}
+			_ = os.Setenv(k, v)
+			exported[k] = v
+		}
+	}
+}
+
+// readFile loads a YAML or JSON config file and flattens it into env-style
+// keys: nested maps are joined with "_" and upper-cased ({db: {host: x}} ->
+// DB_HOST), lists are joined with ",".
+func readFile(path string) (mapSource, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return mapSource{}, err
+	}
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	default:
+		return mapSource{}, fmt.Errorf("config file %s: unsupported extension (want .yaml, .yml or .json)", path)
+	}
+	if err != nil {
+		return mapSource{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	values := map[string]string{}
+	flatten("", doc, values)
+	return mapSource{label: "file(" + path + ")", values: values}, nil
+}
+
+func flatten(prefix string, node any, out map[string]string) {
+	switch n := node.(type) {
+	case map[string]any:
+		for k, v := range n {
+			flatten(joinKey(prefix, k), v, out)
+		}
+	case map[any]any:
+		for k, v := range n {
+			flatten(joinKey(prefix, fmt.Sprint(k)), v, out)
+		}
+	case []any:
+		parts := make([]string, 0, len(n))
+		for _, v := range n {
+			parts = append(parts, fmt.Sprint(v))
+		}
+		out[prefix] = strings.Join(parts, ",")
+	case nil:
+		// explicit null: leave unset so lower layers/defaults apply
+	default:
+		out[prefix] = fmt.Sprint(n)
+	}
+}
+
+func joinKey(prefix, k string) string {
+	k = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(k))
+	if prefix == "" {
+		return k
+	}
+	return prefix + "_" + k
+}
+
+// parseFlags picks flags for known keys out of args. A key like HTTP_ADDR maps
+// to --http-addr (single dash also accepted), written as "--http-addr=:8080"
+// or "--http-addr :8080"; bool flags may omit the value. Everything else,
+// including unknown flags, is left for the application. Parsing stops at "--".
+func parseFlags(args []string, keys map[string]bool) mapSource {
+	byFlag := make(map[string]string, len(keys))
+	for k := range keys {
+		byFlag[flagName(k)] = k
+	}
+	values := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		key, ok := byFlag[name]
+		if !ok {
+			continue
+		}
+		if !hasVal {
+			switch {
+			case keys[key]:
+				val = "true"
+			case i+1 < len(args):
+				i++
+				val = args[i]
+			default:
+				continue
+			}
+		}
+		values[key] = val
+	}
+	return mapSource{label: "flag", values: values}
+}
+
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
@@ -0,0 +1,318 @@
+package config
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+port: 8080
+http-addr: ":80"
+primary:
+  db:
+    host: db1
+    max.conns: 10
+hosts: [a, b]
+replica: null
+debug: true
+`,
+			want: map[string]string{
+				"PORT": "8080", "HTTP_ADDR": ":80", "PRIMARY_DB_HOST": "db1", "PRIMARY_DB_MAX_CONNS": "10",
+				"HOSTS": "a,b", "DEBUG": "true",
+			},
+		},
+		{
+			name:    "json keeps number text",
+			file:    "config.json",
+			content: `{"port": 8080, "ratio": 0.1, "big": 12345678901234567890, "db": {"host": "db1"}, "tags": ["x", 1]}`,
+			want:    map[string]string{"PORT": "8080", "RATIO": "0.1", "BIG": "12345678901234567890", "DB_HOST": "db1", "TAGS": "x,1"},
+		},
+		{name: "yml extension", file: "c.yml", content: "a: 1\n", want: map[string]string{"A": "1"}},
+		{name: "unsupported extension", file: "c.toml", content: "a = 1\n", wantErr: "unsupported extension"},
+		{name: "malformed", file: "c.json", content: "{", wantErr: "parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeFile(t, path, tt.content)
+			src, err := FileSource(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("FileSource() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ms := src.(mapSource)
+			if !maps.Equal(ms.values, tt.want) {
+				t.Errorf("values = %v, want %v", ms.values, tt.want)
+			}
+			if ms.String() != "file("+path+")" {
+				t.Errorf("name = %q", ms.String())
+			}
+		})
+	}
+}
+
+func TestFileNullKeepsDefault(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	unsetenv(t, "NAME")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "name: null\nport: 2\n")
+	var cfg layered
+	if err := LoadWithOptions(&cfg, Options{Dir: t.TempDir(), File: path}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "svc" || cfg.Port != 2 {
+		t.Errorf("got %+v, want Name from its default and Port from the file", cfg)
+	}
+}
+
+// failingSource errors on every lookup, like a remote store that is down.
+type failingSource struct{}
+
+func (failingSource) String() string { return "remote" }
+func (failingSource) Lookup(string) (string, bool, error) {
+	return "", false, errors.New("connection refused")
+}
+
+func TestSourceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{name: "missing file", opts: Options{File: filepath.Join(t.TempDir(), "missing.yaml")}, wantErr: "no such file"},
+		{name: "failing source reported once", opts: Options{Sources: []Source{failingSource{}}}, wantErr: "remote (PORT): connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			unsetenv(t, "PORT")
+			tt.opts.Dir = t.TempDir()
+			var cfg layered
+			err := LoadWithOptions(&cfg, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || strings.Count(err.Error(), "connection refused") > 1 {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type profiled struct {
+	Val   string `env:"PROFILE_VAL"`
+	Other string `env:"PROFILE_OTHER"`
+}
+
+func TestDotEnvProfiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		appEnv     string // real APP_ENV; "" leaves it unset
+		realVal    string // real PROFILE_VAL; "" leaves it unset
+		files      map[string]string
+		want       string
+		wantSource string
+		wantErr    bool
+	}{
+		{name: "no files", want: ""},
+		{
+			name:  ".env only",
+			files: map[string]string{".env": "PROFILE_VAL=base"},
+			want:  "base", wantSource: ".env",
+		},
+		{
+			name:  ".env.local over .env",
+			files: map[string]string{".env": "PROFILE_VAL=base", ".env.local": "PROFILE_VAL=local"},
+			want:  "local", wantSource: ".env.local",
+		},
+		{
+			name:   "profile over .env",
+			appEnv: "staging",
+			files:  map[string]string{".env": "PROFILE_VAL=base", ".env.staging": "PROFILE_VAL=staging", ".env.prod": "PROFILE_VAL=prod"},
+			want:   "staging", wantSource: ".env.staging",
+		},
+		{
+			name:   ".env.local over the profile",
+			appEnv: "staging",
+			files:  map[string]string{".env.staging": "PROFILE_VAL=staging", ".env.local": "PROFILE_VAL=local"},
+			want:   "local", wantSource: ".env.local",
+		},
+		{
+			name:   "profile local over everything",
+			appEnv: "staging",
+			files: map[string]string{
+				".env": "PROFILE_VAL=base", ".env.staging": "PROFILE_VAL=staging",
+				".env.local": "PROFILE_VAL=local", ".env.staging.local": "PROFILE_VAL=staging-local",
+			},
+			want: "staging-local", wantSource: ".env.staging.local",
+		},
+		{
+			name:  "APP_ENV from .env",
+			files: map[string]string{".env": "APP_ENV=qa\nPROFILE_VAL=base", ".env.qa": "PROFILE_VAL=qa"},
+			want:  "qa", wantSource: ".env.qa",
+		},
+		{
+			name:   "real APP_ENV over .env",
+			appEnv: "prod",
+			files:  map[string]string{".env": "APP_ENV=qa", ".env.qa": "PROFILE_VAL=qa", ".env.prod": "PROFILE_VAL=prod"},
+			want:   "prod", wantSource: ".env.prod",
+		},
+		{
+			name:    "real env over all files",
+			appEnv:  "staging",
+			realVal: "real",
+			files:   map[string]string{".env.staging.local": "PROFILE_VAL=staging-local"},
+			want:    "real", wantSource: "env",
+		},
+		{
+			name:    "malformed file",
+			files:   map[string]string{".env": "PROFILE_VAL=base", ".env.local": "PROFILE_VAL='unterminated"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			unsetLoaded(t, "APP_ENV", "PROFILE_VAL", "PROFILE_OTHER")
+			unsetenv(t, "APP_ENV")
+			unsetenv(t, "PROFILE_VAL")
+			if tt.appEnv != "" {
+				t.Setenv("APP_ENV", tt.appEnv)
+			}
+			if tt.realVal != "" {
+				t.Setenv("PROFILE_VAL", tt.realVal)
+			}
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(dir, name), content+"\n")
+			}
+
+			var cfg profiled
+			origins, err := LoadOrigins(&cfg, Options{Dir: dir})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("LoadOrigins() = nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Val != tt.want {
+				t.Errorf("PROFILE_VAL = %q, want %q", cfg.Val, tt.want)
+			}
+			if tt.wantSource == "" {
+				return
+			}
+			src := originOf(origins, "Val").Source
+			if tt.wantSource == "env" {
+				if src != "env" {
+					t.Errorf("source = %q, want env", src)
+				}
+			} else if src != "dotenv("+filepath.Join(dir, tt.wantSource)+")" {
+				t.Errorf("source = %q, want %s", src, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestDotEnvExport(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	unsetLoaded(t, "PROFILE_VAL", "PROFILE_OTHER", "PROFILE_EMPTY")
+	unsetenv(t, "APP_ENV")
+	unsetenv(t, "PROFILE_OTHER")
+	t.Setenv("PROFILE_VAL", "real")
+	t.Setenv("PROFILE_EMPTY", "")
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".env"), "PROFILE_VAL=file\nPROFILE_OTHER=file\nPROFILE_EMPTY=file\n")
+
+	var cfg profiled
+	for i := range 2 {
+		origins, err := LoadOrigins(&cfg, Options{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Exported for code reading os.Getenv, without overriding real env
+		// vars, and still reported as coming from .env on the next load.
+		if got := os.Getenv("PROFILE_OTHER"); got != "file" {
+			t.Errorf("load %d: PROFILE_OTHER in env = %q, want file", i, got)
+		}
+		if got := os.Getenv("PROFILE_VAL"); got != "real" {
+			t.Errorf("load %d: PROFILE_VAL in env = %q, want real", i, got)
+		}
+		if got := os.Getenv("PROFILE_EMPTY"); got != "" {
+			t.Errorf("load %d: PROFILE_EMPTY in env = %q, want it left empty", i, got)
+		}
+		if src := originOf(origins, "Other").Source; !strings.HasPrefix(src, "dotenv(") {
+			t.Errorf("load %d: PROFILE_OTHER source = %q, want dotenv", i, src)
+		}
+	}
+}
+
+func TestConfigFileFromDotEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		realEnv  string // real CONFIG_FILE: "-" unset, "none" set but empty, else a file name
+		dotenv   string // CONFIG_FILE in .env
+		local    string // CONFIG_FILE in .env.local
+		wantPort int
+	}{
+		{name: "no file", realEnv: "-", wantPort: 1},
+		{name: "from .env", realEnv: "-", dotenv: "a.yaml", wantPort: 9},
+		{name: ".env.local wins", realEnv: "-", dotenv: "a.yaml", local: "b.yaml", wantPort: 7},
+		{name: "real env wins", realEnv: "b.yaml", dotenv: "a.yaml", wantPort: 7},
+		{name: "real env empty disables it", realEnv: "none", dotenv: "a.yaml", wantPort: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetLoaded(t, "CONFIG_FILE")
+			unsetenv(t, "PORT")
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "a.yaml"), "port: 9\n")
+			writeFile(t, filepath.Join(dir, "b.yaml"), "port: 7\n")
+			switch tt.realEnv {
+			case "-":
+				unsetenv(t, "CONFIG_FILE")
+			case "none":
+				t.Setenv("CONFIG_FILE", "")
+			default:
+				t.Setenv("CONFIG_FILE", filepath.Join(dir, tt.realEnv))
+			}
+			if tt.dotenv != "" {
+				writeFile(t, filepath.Join(dir, ".env"), "CONFIG_FILE="+filepath.Join(dir, tt.dotenv)+"\n")
+			}
+			if tt.local != "" {
+				writeFile(t, filepath.Join(dir, ".env.local"), "CONFIG_FILE="+filepath.Join(dir, tt.local)+"\n")
+			}
+
+			// The first load must agree with later ones, which find CONFIG_FILE
+			// already exported from .env.
+			for i := range 2 {
+				var cfg layered
+				if err := LoadWithOptions(&cfg, Options{Dir: dir}); err != nil {
+					t.Fatal(err)
+				}
+				if cfg.Port != tt.wantPort {
+					t.Errorf("load %d: Port = %d, want %d", i, cfg.Port, tt.wantPort)
+				}
+			}
+		})
+	}
+}
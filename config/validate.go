@@ -0,0 +1,291 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes why one field failed to load.
+type FieldError struct {
+	Field  string // Go path, e.g. "Primary.Host"
+	Env    string // env name the value was looked up under
+	Value  string // raw value; masked for secret fields
+	Reason string
+}
+
+// Error is returned by Load when one or more fields are missing or invalid.
+// Use errors.As to inspect the individual FieldErrors.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		line := f.Field
+		if f.Env != "" {
+			line += " (" + f.Env + ")"
+		}
+		line += ": " + f.Reason
+		if f.Value != "" {
+			line += fmt.Sprintf(" [value %q]", f.Value)
+		}
+		lines = append(lines, line)
+	}
+	return "configx: \n - " + strings.Join(lines, "\n - ")
+}
+
+// Validator is implemented by config structs (at any nesting level) that need
+// checks beyond tags. It runs after the struct's own fields loaded cleanly.
+type Validator interface {
+	Validate() error
+}
+
+func (ld *loader) fail(f reflect.StructField, field, env, raw, reason string) {
+	ld.errs = append(ld.errs, FieldError{Field: field, Env: env, Value: redact(f, raw), Reason: reason})
+}
+
+// validate runs the Validate hook of the struct at v, unless fields below it
+// already failed (nErrs is len(ld.errs) before the struct was loaded).
+func (ld *loader) validate(v reflect.Value, path string, nErrs int) {
+	if len(ld.errs) > nErrs || !v.CanAddr() || nilEmbeddedValidator(v) {
+		return
+	}
+	h, ok := v.Addr().Interface().(Validator)
+	if !ok {
+		return
+	}
+	err := h.Validate()
+	if err == nil {
+		return
+	}
+	var ce *Error
+	if errors.As(err, &ce) {
+		ld.errs = append(ld.errs, ce.Fields...)
+		return
+	}
+	field := path
+	if field == "" {
+		field = v.Type().Name()
+	}
+	ld.errs = append(ld.errs, FieldError{Field: field, Reason: err.Error()})
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// nilEmbeddedValidator reports whether v embeds, at any depth, a nil pointer
+// whose type has a Validate method. loadStructPtr leaves such pointers nil
+// when none of their fields are set, and calling the promoted Validate would
+// dereference them. Reflection can't tell a promoted Validate from one the
+// struct declares itself, so both are skipped; there is nothing to validate
+// in the nil part anyway.
+func nilEmbeddedValidator(v reflect.Value) bool {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Type.Kind() == reflect.Pointer {
+			if !f.Type.Implements(validatorType) {
+				continue
+			}
+			if fv.IsNil() {
+				return true
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && nilEmbeddedValidator(fv) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTags applies the declarative validation tags to a parsed field:
+//
+//	min:"1" max:"65535"   numbers and durations by value; strings, slices and maps by length
+//	oneof:"a b c"         value (or each element) must be one of the space-separated options
+//	regex:"^[a-z]+$"      value (or each element) must match
+//	url:"true"            absolute URL with scheme and host
+//	hostport:"true"       "host:port" or ":port"
+//	nonempty:"true"       string, slice or map must not be empty
+//
+// Format checks skip empty strings; combine with nonempty to forbid those.
+func checkTags(fv reflect.Value, tag reflect.StructTag) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if tag.Get("nonempty") == "true" {
+		switch fv.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map:
+			if fv.Len() == 0 {
+				return errors.New("must not be empty")
+			}
+		}
+	}
+	for _, b := range []struct {
+		key string
+		min bool
+	}{{"min", true}, {"max", false}} {
+		bound := tag.Get(b.key)
+		if bound == "" {
+			continue
+		}
+		if err := checkBound(fv, bound, b.min); err != nil {
+			return err
+		}
+	}
+
+	items := textValues(fv)
+	if opts := tag.Get("oneof"); opts != "" {
+		allowed := strings.Fields(opts)
+		for _, s := range items {
+			if !contains(allowed, s) {
+				return fmt.Errorf("must be one of [%s]", strings.Join(allowed, ", "))
+			}
+		}
+	}
+	if expr := tag.Get("regex"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("bad regex tag %q: %v", expr, err)
+		}
+		for _, s := range items {
+			if s != "" && !re.MatchString(s) {
+				return fmt.Errorf("must match %s", expr)
+			}
+		}
+	}
+	if tag.Get("url") == "true" {
+		for _, s := range items {
+			if u, err := url.Parse(s); s != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+				return errors.New("must be an absolute URL")
+			}
+		}
+	}
+	if tag.Get("hostport") == "true" {
+		for _, s := range items {
+			if s == "" {
+				continue
+			}
+			_, port, err := net.SplitHostPort(s)
+			if err == nil {
+				_, err = strconv.ParseUint(port, 10, 16)
+			}
+			if err != nil {
+				return errors.New("must be host:port")
+			}
+		}
+	}
+	return nil
+}
+
+// checkBound compares fv against a min (isMin) or max bound. The bound is
+// parsed as the field's own type, so durations accept "1s" and so on.
+func checkBound(fv reflect.Value, bound string, isMin bool) error {
+	word := "at most"
+	if isMin {
+		word = "at least"
+	}
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		n, err := strconv.Atoi(bound)
+		if err != nil {
+			return fmt.Errorf("bad bound %q", bound)
+		}
+		if (isMin && fv.Len() < n) || (!isMin && fv.Len() > n) {
+			return fmt.Errorf("length must be %s %d", word, n)
+		}
+		return nil
+	}
+	bv := reflect.New(fv.Type()).Elem()
+	if err := setField(bv, bound, ""); err != nil {
+		return fmt.Errorf("bad bound %q", bound)
+	}
+	var cmp int
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		cmp = compare(fv.Int(), bv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		cmp = compare(fv.Uint(), bv.Uint())
+	case reflect.Float32, reflect.Float64:
+		cmp = compare(fv.Float(), bv.Float())
+	default:
+		return fmt.Errorf("min/max not supported for %s", fv.Type())
+	}
+	if (isMin && cmp < 0) || (!isMin && cmp > 0) {
+		return fmt.Errorf("must be %s %s", word, bound)
+	}
+	return nil
+}
+
+func compare[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// textValues returns the string form of a scalar, or of each slice element.
+func textValues(fv reflect.Value) []string {
+	if fv.Kind() == reflect.Slice && fv.Type() != reflect.TypeOf(net.IP(nil)) {
+		out := make([]string, fv.Len())
+		for i := range out {
+			out[i] = fmt.Sprint(fv.Index(i).Interface())
+		}
+		return out
+	}
+	return []string{fmt.Sprint(fv.Interface())}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// redact masks the raw value of secret fields (see IsSecret).
+func redact(f reflect.StructField, raw string) string {
+	if raw == "" || !IsSecret(f) {
+		return raw
+	}
+	return mask
+}
+
+const mask = "****"
+
+// IsSecret reports whether a field's value must never be shown: an explicit
+// `secret:"true"` (or "false" to opt out), encrypted or file-backed fields,
+// and names mentioning passwords, secrets, tokens or keys.
+func IsSecret(f reflect.StructField) bool {
+	if v, ok := f.Tag.Lookup("secret"); ok {
+		return v == "true"
+	}
+	if f.Tag.Get("encrypted") == "true" || f.Tag.Get("file") == "true" {
+		return true
+	}
+	name := strings.ToUpper(f.Name + " " + f.Tag.Get("env"))
+	for _, w := range []string{"PASSWORD", "SECRET", "TOKEN", "KEY"} {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bounded struct {
+	Port    int           `min:"1" max:"65535"`
+	Ratio   float64       `min:"0" max:"1"`
+	Timeout time.Duration `min:"1s" max:"1m"`
+	Name    string        `min:"2" max:"5"`
+	Tags    []string      `max:"2"`
+	Mode    string        `oneof:"dev prod"`
+	Modes   []string      `oneof:"a b"`
+	Slug    string        `regex:"^[a-z-]+$"`
+	Target  string        `url:"true"`
+	Addr    string        `hostport:"true"`
+	Must    []string      `nonempty:"true"`
+	Opt     *int          `min:"10"`
+}
+
+func TestCheckTags(t *testing.T) {
+	tests := []struct {
+		field   string
+		raw     string
+		wantErr string // "" for valid
+	}{
+		{field: "Port", raw: "8080"},
+		{field: "Port", raw: "0", wantErr: "must be at least 1"},
+		{field: "Port", raw: "65536", wantErr: "must be at most 65535"},
+		{field: "Ratio", raw: "0.5"},
+		{field: "Ratio", raw: "1.5", wantErr: "must be at most 1"},
+		{field: "Timeout", raw: "30s"},
+		{field: "Timeout", raw: "500ms", wantErr: "must be at least 1s"},
+		{field: "Timeout", raw: "2m", wantErr: "must be at most 1m"},
+		{field: "Name", raw: "abc"},
+		{field: "Name", raw: "a", wantErr: "length must be at least 2"},
+		{field: "Name", raw: "abcdef", wantErr: "length must be at most 5"},
+		{field: "Tags", raw: "a,b,c", wantErr: "length must be at most 2"},
+		{field: "Mode", raw: "prod"},
+		{field: "Mode", raw: "staging", wantErr: "must be one of [dev, prod]"},
+		{field: "Modes", raw: "a,b"},
+		{field: "Modes", raw: "a,c", wantErr: "must be one of [a, b]"},
+		{field: "Slug", raw: "my-svc"},
+		{field: "Slug", raw: "My_Svc", wantErr: "must match"},
+		{field: "Target", raw: "https://example.com/x"},
+		{field: "Target", raw: "example.com", wantErr: "absolute URL"},
+		{field: "Target", raw: ""}, // format checks skip empty strings
+		{field: "Addr", raw: "localhost:8080"},
+		{field: "Addr", raw: ":8080"},
+		{field: "Addr", raw: "localhost", wantErr: "host:port"},
+		{field: "Addr", raw: "localhost:99999", wantErr: "host:port"},
+		{field: "Must", raw: "x"},
+		{field: "Must", raw: " , ", wantErr: "must not be empty"},
+		{field: "Opt", raw: "5", wantErr: "must be at least 10"},
+	}
+	typ := reflect.TypeOf(bounded{})
+	for _, tt := range tests {
+		t.Run(tt.field+"="+tt.raw, func(t *testing.T) {
+			f, _ := typ.FieldByName(tt.field)
+			fv := reflect.New(f.Type).Elem()
+			if err := setField(fv, tt.raw, f.Tag); err != nil {
+				t.Fatal(err)
+			}
+			err := checkTags(fv, f.Tag)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkTags() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkTags() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type pool struct {
+	Min int `default:"1"`
+	Max int `default:"10"`
+}
+
+func (p *pool) Validate() error {
+	if p.Min > p.Max {
+		return errors.New("min exceeds max")
+	}
+	return nil
+}
+
+type window struct {
+	From int
+	To   int
+}
+
+func (w window) Validate() error {
+	if w.From > w.To {
+		return &Error{Fields: []FieldError{{Field: "Window.From", Env: "W_FROM", Reason: "after To"}}}
+	}
+	return nil
+}
+
+type validated struct {
+	Port     int    `required:"true" min:"1"`
+	Mode     string `oneof:"dev prod" default:"dev"`
+	Password string `min:"8"`
+	Pool     pool   `envPrefix:"POOL_"`
+	Window   window `envPrefix:"W_"`
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   []FieldError
+	}{
+		{name: "valid", values: map[string]string{"PORT": "80"}},
+		{
+			name:   "all problems collected",
+			values: map[string]string{"MODE": "qa", "PASSWORD": "short"},
+			want: []FieldError{
+				{Field: "Port", Env: "PORT", Reason: "missing required value"},
+				{Field: "Mode", Env: "MODE", Value: "qa", Reason: "must be one of [dev, prod]"},
+				{Field: "Password", Env: "PASSWORD", Value: "****", Reason: "length must be at least 8"},
+			},
+		},
+		{
+			name:   "parse error",
+			values: map[string]string{"PORT": "eighty"},
+			want:   []FieldError{{Field: "Port", Env: "PORT", Value: "eighty", Reason: `invalid int "eighty"`}},
+		},
+		{
+			name:   "validator on a nested struct",
+			values: map[string]string{"PORT": "80", "POOL_MIN": "20"},
+			want:   []FieldError{{Field: "Pool", Reason: "min exceeds max"}},
+		},
+		{
+			name:   "validator skipped when its fields failed",
+			values: map[string]string{"PORT": "80", "POOL_MIN": "20", "POOL_MAX": "x"},
+			want:   []FieldError{{Field: "Pool.Max", Env: "POOL_MAX", Value: "x", Reason: `invalid int "x"`}},
+		},
+		{
+			name:   "validator returning field errors",
+			values: map[string]string{"PORT": "80", "W_FROM": "5", "W_TO": "1"},
+			want:   []FieldError{{Field: "Window.From", Env: "W_FROM", Reason: "after To"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"PORT", "MODE", "PASSWORD"} {
+				unsetenv(t, k)
+			}
+			var cfg validated
+			err := loadFrom(t, &cfg, tt.values)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var ce *Error
+			if !errors.As(err, &ce) {
+				t.Fatalf("err = %v, want *Error", err)
+			}
+			if !slices.Equal(ce.Fields, tt.want) {
+				t.Errorf("fields =\n%+v\nwant\n%+v", ce.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Fields: []FieldError{
+		{Field: "Port", Env: "PORT", Reason: "missing required value"},
+		{Field: "Mode", Env: "MODE", Value: "qa", Reason: "must be one of [dev, prod]"},
+		{Field: "Pool", Reason: "min exceeds max"},
+	}}
+	want := "configx: \n" +
+		" - Port (PORT): missing required value\n" +
+		` - Mode (MODE): must be one of [dev, prod] [value "qa"]` + "\n" +
+		" - Pool: min exceeds max"
+	if err.Error() != want {
+		t.Errorf("Error() =\n%s\nwant\n%s", err.Error(), want)
+	}
+}
+
+func TestIsSecret(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  reflect.StructTag
+		want bool
+	}{
+		{name: "Host", want: false},
+		{name: "DBPassword", want: true},
+		{name: "ClientSecret", want: true},
+		{name: "APIToken", want: true},
+		{name: "SigningKey", want: true},
+		{name: "Conn", tag: `env:"REDIS_PASSWORD"`, want: true},
+		{name: "PublicKey", tag: `secret:"false"`, want: false},
+		{name: "Dsn", tag: `secret:"true"`, want: true},
+		{name: "Cert", tag: `file:"true"`, want: true},
+		{name: "Blob", tag: `encrypted:"true"`, want: true},
+	}
+	for _, tt := range tests {
+		if got := IsSecret(reflect.StructField{Name: tt.name, Tag: tt.tag}); got != tt.want {
+			t.Errorf("IsSecret(%s %s) = %v, want %v", tt.name, tt.tag, got, tt.want)
+		}
+	}
+}
+
+type TLSOpts struct {
+	CertFile string `env:"EMB_CERT_FILE"`
+	KeyFile  string `env:"EMB_KEY_FILE"`
+}
+
+func (o TLSOpts) Validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("cert and key go together")
+	}
+	return nil
+}
+
+type embedsPtr struct {
+	*TLSOpts
+	Port int `env:"EMB_PORT"`
+}
+
+func TestLoadNilEmbeddedValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		wantSet bool
+		wantErr string
+	}{
+		{name: "none set leaves it nil", values: map[string]string{"EMB_PORT": "1"}},
+		{name: "set and valid", values: map[string]string{"EMB_CERT_FILE": "c", "EMB_KEY_FILE": "k"}, wantSet: true},
+		{name: "set and invalid", values: map[string]string{"EMB_CERT_FILE": "c"}, wantSet: true, wantErr: "cert and key go together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg embedsPtr
+			err := loadFrom(t, &cfg, tt.values)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if (cfg.TLSOpts != nil) != tt.wantSet {
+				t.Errorf("embedded pointer set = %v, want %v", cfg.TLSOpts != nil, tt.wantSet)
+			}
+		})
+	}
+}
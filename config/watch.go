@@ -0,0 +1,182 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ranakdinesh/spur/logger"
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	Options // used for every (re)load
+
+	// How often the config file and .env are checked for changes (default 5s).
+	Interval time.Duration
+
+	// Optional; applied and rejected reloads are logged here.
+	Log *logger.Loggerx
+}
+
+// Watcher holds the current config snapshot. Snapshots are immutable: a
+// reload builds a fresh *T and swaps it in atomically, so readers should call
+// Load each time instead of caching the pointer.
+type Watcher[T any] struct {
+	cur  atomic.Pointer[snapshot[T]]
+	opts WatchOptions
+
+	mu   sync.Mutex // serializes reloads and guards subs
+	subs []func(old, new *T)
+}
+
+type snapshot[T any] struct {
+	cfg     *T
+	origins Origins
+}
+
+// Watch loads T and keeps reloading it until ctx is done: when the config
+// file or a .env file changes, a WatchableSource reports a change, or the
+// process receives SIGHUP. A reload that fails to load or validate is logged
+// and the previous snapshot is kept.
+func Watch[T any](ctx context.Context, opts WatchOptions) (*Watcher[T], error) {
+	if opts.Interval == 0 {
+		opts.Interval = 5 * time.Second
+	}
+	w := &Watcher[T]{opts: opts}
+	// Taken before loading, so a change made meanwhile triggers a reload.
+	last := fingerprint(w.files())
+	first := new(T)
+	origins, err := LoadOrigins(first, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	w.cur.Store(&snapshot[T]{cfg: first, origins: origins})
+	go w.loop(ctx, last)
+	return w, nil
+}
+
+// Load returns the current snapshot.
+func (w *Watcher[T]) Load() *T { return w.cur.Load().cfg }
+
+// Origins returns where each field of the current snapshot came from.
+func (w *Watcher[T]) Origins() Origins { return w.cur.Load().origins }
+
+// OnChange registers fn to run after a new snapshot has been swapped in.
+// Callbacks run sequentially on the reloading goroutine, outside the
+// watcher's lock, so they may call OnChange or Reload themselves.
+func (w *Watcher[T]) OnChange(fn func(old, new *T)) {
+	w.mu.Lock()
+	w.subs = append(w.subs, fn)
+	w.mu.Unlock()
+}
+
+// Handler serves the current snapshot; see config.Handler.
+func (w *Watcher[T]) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		Handler(w.Load()).ServeHTTP(rw, r)
+	})
+}
+
+// Reload loads a new snapshot now. On error the current one is kept.
+func (w *Watcher[T]) Reload(ctx context.Context) error {
+	w.mu.Lock()
+	next := new(T)
+	origins, err := LoadOrigins(next, w.opts.Options)
+	if err != nil {
+		w.mu.Unlock()
+		if w.opts.Log != nil {
+			w.opts.Log.Warn(ctx).Err(err).Msg("config reload rejected; keeping previous config")
+		}
+		return err
+	}
+	old := w.cur.Load().cfg
+	if reflect.DeepEqual(old, next) {
+		w.mu.Unlock()
+		return nil
+	}
+	w.cur.Store(&snapshot[T]{cfg: next, origins: origins})
+	subs := append([]func(old, new *T){}, w.subs...)
+	w.mu.Unlock()
+
+	if w.opts.Log != nil {
+		w.opts.Log.Info(ctx).Msg("config reloaded")
+	}
+	for _, fn := range subs {
+		fn(old, next)
+	}
+	return nil
+}
+
+func (w *Watcher[T]) loop(ctx context.Context, last string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	t := time.NewTicker(w.opts.Interval)
+	defer t.Stop()
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+	for _, src := range w.opts.Sources {
+		if ws, ok := src.(WatchableSource); ok {
+			go func() {
+				if err := ws.Watch(ctx, notify); err != nil && ctx.Err() == nil && w.opts.Log != nil {
+					w.opts.Log.Warn(ctx).Err(err).Str("source", sourceName(ws)).Msg("config source watch stopped")
+				}
+			}()
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			_ = w.Reload(ctx)
+		case <-hup:
+			_ = w.Reload(ctx)
+			last = fingerprint(w.files())
+		case <-t.C:
+			if fp := fingerprint(w.files()); fp != last {
+				last = fp
+				_ = w.Reload(ctx)
+			}
+		}
+	}
+}
+
+// files lists what the watcher polls: the .env profile files and the config file.
+func (w *Watcher[T]) files() []string {
+	files := dotEnvFiles(w.opts.Dir, os.Getenv("APP_ENV"))
+	if f := w.opts.File; f != "" {
+		files = append(files, f)
+	} else if f := os.Getenv("CONFIG_FILE"); f != "" {
+		files = append(files, f)
+	}
+	return files
+}
+
+// fingerprint summarizes size and mtime of files; missing files count as empty.
+func fingerprint(files []string) string {
+	var b strings.Builder
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", f, st.ModTime().UnixNano(), st.Size())
+		}
+	}
+	return b.String()
+}
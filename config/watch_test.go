@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type watched struct {
+	Port int `default:"1" min:"1"`
+}
+
+func TestWatcherReload(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string // new config file content
+		wantErr    bool
+		wantPort   int
+		wantChange bool
+	}{
+		{name: "changed value", file: "port: 2\n", wantPort: 2, wantChange: true},
+		{name: "same value", file: "port: 1\n", wantPort: 1},
+		{name: "invalid value keeps previous", file: "port: 0\n", wantErr: true, wantPort: 1},
+		{name: "unparseable file keeps previous", file: "port: [\n", wantErr: true, wantPort: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "PORT")
+			dir := t.TempDir()
+			file := filepath.Join(dir, "config.yaml")
+			writeFile(t, file, "port: 1\n")
+			w, err := Watch[watched](t.Context(), WatchOptions{Options: Options{Dir: dir, File: file}, Interval: time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := w.Load()
+			changes := 0
+			w.OnChange(func(old, new *watched) {
+				changes++
+				if old != first || new != w.Load() {
+					t.Errorf("OnChange(%p, %p), want (%p, %p)", old, new, first, w.Load())
+				}
+			})
+
+			writeFile(t, file, tt.file)
+			if err := w.Reload(context.Background()); (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := w.Load().Port; got != tt.wantPort {
+				t.Errorf("Port = %d, want %d", got, tt.wantPort)
+			}
+			if got := changes > 0; got != tt.wantChange {
+				t.Errorf("OnChange called %d times, want change %v", changes, tt.wantChange)
+			}
+			if tt.wantChange && first.Port != 1 {
+				t.Errorf("old snapshot was modified: Port = %d", first.Port)
+			}
+			if got := originOf(w.Origins(), "Port").Source; got != "file("+file+")" {
+				t.Errorf("Origins Port source = %q", got)
+			}
+		})
+	}
+}
+
+func TestWatcherPicksUpFileChanges(t *testing.T) {
+	unsetenv(t, "PORT")
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "port: 1\n")
+	w, err := Watch[watched](t.Context(), WatchOptions{Options: Options{Dir: dir, File: file}, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(chan int, 1)
+	w.OnChange(func(_, new *watched) { changed <- new.Port })
+
+	writeFile(t, file, "port: 42\n")
+	select {
+	case got := <-changed:
+		if got != 42 {
+			t.Errorf("Port = %d, want 42", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("file change not picked up")
+	}
+}
+
+func TestWatcherCallbacksRunOutsideLock(t *testing.T) {
+	unsetenv(t, "PORT")
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "port: 1\n")
+	w, err := Watch[watched](t.Context(), WatchOptions{Options: Options{Dir: dir, File: file}, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	w.OnChange(func(_, _ *watched) {
+		// Both take the watcher's lock; this deadlocked while callbacks
+		// ran under it.
+		w.OnChange(func(_, _ *watched) {})
+		_ = w.Reload(context.Background())
+		close(done)
+	})
+	writeFile(t, file, "port: 2\n")
+	go func() { _ = w.Reload(context.Background()) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback blocked on the watcher lock")
+	}
+}
@@ -2,22 +2,41 @@ package httpserver
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ranakdinesh/spur/logger"
+	"github.com/rs/zerolog"
 )
 
 // MountFunc lets parent apps add multiple routes at once.
 type MountFunc func(r chi.Router)
 
 type Server struct {
-	http   *http.Server
-	log    *logger.Loggerx
-	router chi.Router
+	http    *http.Server
+	log     *logger.Loggerx
+	router  chi.Router
+	opts    Options
+	tlsErr  error // invalid TLS settings, returned by Start
+	corsErr error // invalid CORS policy, returned by Start
+
+	ready atomic.Bool // /readyz; true while serving and not draining
+
+	hooksMu sync.Mutex
+	hooks   []shutdownHook
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
 }
 
 // NewServer builds a hardened HTTP server and allows the parent to mount routes.
@@ -34,6 +53,9 @@ func NewServer(opts Options, log *logger.Loggerx, initialMount MountFunc) *Serve
 	if opts.IdleTimeout == 0 {
 		opts.IdleTimeout = 60 * time.Second
 	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = 10 * time.Second
+	}
 	if opts.EnableSecurityHeaders == false {
 		opts.EnableSecurityHeaders = true // default ON
 	}
@@ -43,14 +65,23 @@ func NewServer(opts Options, log *logger.Loggerx, initialMount MountFunc) *Serve
 	// Core middlewares
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
+	r.Use(ClientIdentity())
 	r.Use(middleware.Recoverer)
 	r.Use(RequestLogger(log))
 
 	if opts.MaxBodyBytes > 0 {
 		r.Use(MaxBytes(opts.MaxBodyBytes))
 	}
+	var corsErr error
 	if opts.EnableCORS {
-		r.Use(CORS(opts))
+		var cors func(http.Handler) http.Handler
+		if cors, corsErr = newCORS(opts); corsErr == nil {
+			r.Use(cors)
+		}
+	}
+	// After CORS: preflights aren't counted and a 429 stays readable.
+	if opts.RateLimit != nil {
+		r.Use(RateLimit(*opts.RateLimit))
 	}
 	if opts.EnableSecurityHeaders {
 		r.Use(SecurityHeaders())
@@ -58,23 +89,34 @@ func NewServer(opts Options, log *logger.Loggerx, initialMount MountFunc) *Serve
 
 	// Health endpoints
 	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
-	r.Get("/readyz", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	srv := &Server{log: log, router: r, opts: opts, corsErr: corsErr}
+	r.Get("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !srv.ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
 
 	// Allow initial mount for convenience
 	if initialMount != nil {
 		initialMount(r)
 	}
 
-	s := &http.Server{
+	srv.http = &http.Server{
 		Addr:              opts.Addr,
 		Handler:           r,
 		ReadTimeout:       opts.ReadTimeout,
 		ReadHeaderTimeout: opts.ReadHeaderTimeout,
 		WriteTimeout:      opts.WriteTimeout,
 		IdleTimeout:       opts.IdleTimeout,
+		// TLS handshake and other net/http errors in our JSON format.
+		ErrorLog: logger.StdLogger(log.Named("http"), zerolog.WarnLevel),
 	}
 
-	return &Server{http: s, log: log, router: r}
+	srv.http.TLSConfig, srv.tlsErr = newTLSConfig(opts, log.Named("http"))
+
+	return srv
 }
 
 // ---- Public mounting API ----
@@ -139,18 +181,98 @@ func hostOnly(host string) func(http.Handler) http.Handler {
 	}
 }
 
-// Start runs the server and shuts down gracefully when ctx is canceled.
+// Ready reports whether the server is serving and not draining (what
+// /readyz answers).
+func (s *Server) Ready() bool { return s.ready.Load() }
+
+// OnShutdown registers fn to run once the server has drained, in
+// registration order: e.g. the DB pool, then Redis, then the log sinks.
+// Hooks get what is left of ShutdownTimeout after the drain; a failing hook
+// doesn't stop later ones.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Start listens on Addr (HTTPS if TLS is configured) and serves until ctx is
+// canceled, then shuts down: mark not ready, wait PreStopDelay, then drain
+// and run the OnShutdown hooks, both within one ShutdownTimeout. Invalid TLS
+// or CORS settings and listen errors (e.g. port in use) are returned right
+// away; a serve error also runs the hooks.
 func (s *Server) Start(ctx context.Context) error {
+	if s.tlsErr != nil {
+		return s.tlsErr
+	}
+	if s.corsErr != nil {
+		return s.corsErr
+	}
+	addr := s.http.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("httpserver: listen: %w", err)
+	}
+	s.log.Info(ctx).Str("addr", ln.Addr().String()).Bool("tls", s.http.TLSConfig != nil).Msg("http server: listening")
+
+	serveErr := make(chan error, 1)
 	go func() {
-		s.log.Info(ctx).Str("addr", s.http.Addr).Msg("http server: listening")
-		if err := s.http.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.log.Error(ctx).Err(err).Msg("http server: fatal")
+		if s.http.TLSConfig != nil {
+			serveErr <- s.http.ServeTLS(ln, "", "") // certificates come from TLSConfig
+			return
 		}
+		serveErr <- s.http.Serve(ln)
 	}()
-	<-ctx.Done()
+	s.ready.Store(true)
+
+	select {
+	case err := <-serveErr:
+		s.ready.Store(false)
+		s.log.Error(ctx).Err(err).Msg("http server: serve failed")
+		hookCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+		defer cancel()
+		return errors.Join(fmt.Errorf("httpserver: serve: %w", err), s.runHooks(ctx, hookCtx))
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	if d := s.opts.PreStopDelay; d > 0 {
+		s.log.Info(ctx).Dur("pre_stop_delay", d).Msg("http server: not ready, waiting for deregistration")
+		time.Sleep(d)
+	}
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
 	defer cancel()
 	s.log.Info(ctx).Msg("http server: shutting down")
-	return s.http.Shutdown(shutdownCtx)
+	err = s.http.Shutdown(shutdownCtx)
+	if err != nil {
+		s.log.Warn(ctx).Err(err).Msg("http server: drain incomplete, closing remaining connections")
+		_ = s.http.Close()
+	}
+	return errors.Join(err, s.runHooks(ctx, shutdownCtx))
+}
+
+// runHooks flushes buffered remote log lines (including the shutdown ones),
+// then runs the OnShutdown hooks within hookCtx. A hook that closes the
+// logger should come last so it delivers what the others log.
+func (s *Server) runHooks(ctx, hookCtx context.Context) error {
+	if ferr := s.log.Flush(hookCtx); ferr != nil {
+		s.log.Warn(ctx).Err(ferr).Msg("http server: log flush failed")
+	}
+
+	s.hooksMu.Lock()
+	hooks := s.hooks
+	s.hooks = nil // run once
+	s.hooksMu.Unlock()
+
+	var errs []error
+	for _, h := range hooks {
+		if err := h.fn(hookCtx); err != nil {
+			s.log.Error(ctx).Err(err).Str("hook", h.name).Msg("http server: shutdown hook failed")
+			errs = append(errs, fmt.Errorf("httpserver: shutdown hook %s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,233 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ranakdinesh/spur/logger"
+)
+
+// addrLog captures the address Start logs once it listens.
+type addrLog struct {
+	once sync.Once
+	addr chan string
+}
+
+func (l *addrLog) Write(p []byte) (int, error) {
+	var line struct {
+		Msg  string `json:"msg"`
+		Addr string `json:"addr"`
+	}
+	if json.Unmarshal(p, &line) == nil && line.Addr != "" {
+		l.once.Do(func() { l.addr <- line.Addr })
+	}
+	return len(p), nil
+}
+
+// startServer runs srv.Start in the background and returns its address, a
+// stop function and the channel Start's result arrives on.
+func startServer(t *testing.T, opts Options, mount MountFunc) (srv *Server, addr string, stop context.CancelFunc, done <-chan error) {
+	t.Helper()
+	if opts.Addr == "" {
+		opts.Addr = "127.0.0.1:0"
+	}
+	al := &addrLog{addr: make(chan string, 1)}
+	srv = NewServer(opts, logger.NewWithWriter(al, logger.Options{Level: "info"}), mount)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Start(ctx) }()
+	select {
+	case addr = <-al.addr:
+	case err := <-errc:
+		cancel()
+		t.Fatalf("Start() = %v", err)
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatal("server did not start")
+	}
+	t.Cleanup(cancel)
+	return srv, addr, cancel, errc
+}
+
+func status(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	note := func(s string) {
+		mu.Lock()
+		events = append(events, s)
+		mu.Unlock()
+	}
+	srv, addr, stop, done := startServer(t, Options{PreStopDelay: 200 * time.Millisecond}, func(r chi.Router) {
+		r.Get("/slow", func(w http.ResponseWriter, _ *http.Request) {
+			close(entered)
+			<-release
+			note("request done")
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+	srv.OnShutdown("db", func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("hook context has no deadline")
+		}
+		note("db")
+		return nil
+	})
+	srv.OnShutdown("cache", func(context.Context) error { note("cache"); return nil })
+
+	base := "http://" + addr
+	if got := status(t, base+"/readyz"); got != http.StatusOK || !srv.Ready() {
+		t.Fatalf("/readyz = %d before shutdown", got)
+	}
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			t.Error(err)
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-entered
+
+	stop()
+	// During PreStopDelay new requests are still served, but not ready.
+	time.Sleep(50 * time.Millisecond)
+	if got := status(t, base+"/readyz"); got != http.StatusServiceUnavailable || srv.Ready() {
+		t.Errorf("/readyz = %d while draining, want 503", got)
+	}
+	if got := status(t, base+"/healthz"); got != http.StatusOK {
+		t.Errorf("/healthz = %d while draining", got)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Start returned %v with a request in flight", err)
+	default:
+	}
+
+	close(release)
+	if got := <-slow; got != http.StatusOK {
+		t.Errorf("in-flight request = %d, want 200", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Start() = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"request done", "db", "cache"}; !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestServerShutdownHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    []bool // per hook
+		wantErr []string
+	}{
+		{name: "none"},
+		{name: "all succeed", fail: []bool{false, false, false}},
+		{name: "failure doesn't stop later hooks", fail: []bool{false, true, false, true}, wantErr: []string{"hook h1", "hook h3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, stop, done := startServer(t, Options{}, nil)
+			var ran []int
+			for i, fail := range tt.fail {
+				srv.OnShutdown(fmt.Sprintf("h%d", i), func(context.Context) error {
+					ran = append(ran, i)
+					if fail {
+						return errors.New("boom")
+					}
+					return nil
+				})
+			}
+			stop()
+			err := <-done
+			if len(ran) != len(tt.fail) || !slices.IsSorted(ran) {
+				t.Errorf("hooks ran %v", ran)
+			}
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Start() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Start() = nil, want the hook errors")
+			}
+			for _, w := range tt.wantErr {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q doesn't mention %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestServerStartErrors(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{name: "port in use", opts: Options{Addr: busy.Addr().String()}, wantErr: "httpserver: listen"},
+		{name: "bad tls settings", opts: Options{Addr: "127.0.0.1:0", TLSCertFile: "cert.pem"}, wantErr: "must be set together"},
+		{name: "bad cors settings", opts: Options{Addr: "127.0.0.1:0", EnableCORS: true, AllowCredentials: true}, wantErr: "AllowCredentials"},
+		{name: "bad cors route policy", opts: Options{Addr: "127.0.0.1:0", EnableCORS: true, CORSPolicies: map[string]CORSOptions{
+			"/public": {AllowedOriginPatterns: []string{`https://(`}},
+		}}, wantErr: `(policy "/public")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(tt.opts, logger.NewWithWriter(io.Discard, logger.Options{}), nil)
+			hookRan := false
+			srv.OnShutdown("hook", func(context.Context) error { hookRan = true; return nil })
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := srv.Start(ctx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Start() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if ctx.Err() != nil {
+				t.Error("Start waited for the context instead of failing right away")
+			}
+			if hookRan || srv.Ready() {
+				t.Errorf("hook ran = %v, ready = %v", hookRan, srv.Ready())
+			}
+		})
+	}
+}
@@ -1,52 +1,302 @@
 package httpserver
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// CORSOptions is one CORS policy.
+type CORSOptions struct {
+	// Origins allowed to call: exact ("https://app.example.com"), wildcard
+	// subdomains ("https://*.example.com") or "*" for any. The matched origin
+	// is echoed in Access-Control-Allow-Origin.
+	AllowedOrigins []string
+	// Regexes matched against the whole origin, e.g.
+	// `https://pr-\d+\.preview\.example\.com`. An invalid one is a config
+	// error: Start returns it, CORS and CORSPolicy panic.
+	AllowedOriginPatterns []string
+
+	AllowedMethods []string // nil => GET,POST,PUT,PATCH,DELETE,OPTIONS
+	AllowedHeaders []string // nil => common headers; "*" allows any requested header
+	ExposedHeaders []string // response headers scripts may read
+
+	// AllowCredentials lets browsers send cookies and Authorization. It
+	// can't be combined with "*" (or the default origins); that is a config
+	// error like an invalid pattern.
+	AllowCredentials bool
+
+	MaxAge time.Duration // preflight cache time (default 5m; < 0 disables caching)
+
+	// AllowPrivateNetwork answers Private Network Access preflights, needed
+	// when a public site calls this server on a private address.
+	AllowPrivateNetwork bool
+}
+
+// CORS applies the policy from Options; paths under a CORSPolicies prefix
+// use that policy instead (longest prefix wins, matched on whole path
+// segments: "/public" covers "/public" and "/public/x", not "/publicity").
+// It panics on an invalid policy; NewServer reports that from Start instead.
 func CORS(opts Options) func(http.Handler) http.Handler {
-	allowedOrigins := opts.AllowedOrigins
-	if len(allowedOrigins) == 0 {
-		allowedOrigins = []string{"*"}
+	mw, err := newCORS(opts)
+	if err != nil {
+		panic(err)
 	}
-	allowedMethods := opts.AllowedMethods
-	if len(allowedMethods) == 0 {
-		allowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	return mw
+}
+
+// newCORS builds the CORS middleware, or the first policy error.
+func newCORS(opts Options) (func(http.Handler) http.Handler, error) {
+	def, err := newCORSPolicy(CORSOptions{
+		AllowedOrigins:        opts.AllowedOrigins,
+		AllowedOriginPatterns: opts.AllowedOriginPatterns,
+		AllowedMethods:        opts.AllowedMethods,
+		AllowedHeaders:        opts.AllowedHeaders,
+		ExposedHeaders:        opts.ExposedHeaders,
+		AllowCredentials:      opts.AllowCredentials,
+		MaxAge:                opts.CORSMaxAge,
+		AllowPrivateNetwork:   opts.AllowPrivateNetwork,
+	})
+	if err != nil {
+		return nil, err
 	}
-	allowedHeaders := opts.AllowedHeaders
-	if len(allowedHeaders) == 0 {
-		allowedHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Requested-With", "X-CSRF-Token"}
+	prefixes := make([]string, 0, len(opts.CORSPolicies))
+	routes := make(map[string]*corsPolicy, len(opts.CORSPolicies))
+	for p, c := range opts.CORSPolicies {
+		p = strings.TrimRight(p, "/")
+		prefixes = append(prefixes, p)
+		if routes[p], err = newCORSPolicy(c); err != nil {
+			return nil, fmt.Errorf("%w (policy %q)", err, p)
+		}
 	}
-
-	origins := strings.Join(allowedOrigins, ", ")
-	methods := strings.Join(allowedMethods, ", ")
-	headers := strings.Join(allowedHeaders, ", ")
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Vary", "Origin")
-			w.Header().Set("Vary", "Access-Control-Request-Method")
-			w.Header().Set("Vary", "Access-Control-Request-Headers")
+			p := def
+			for _, prefix := range prefixes {
+				if underPrefix(r.URL.Path, prefix) {
+					p = routes[prefix]
+					break
+				}
+			}
+			p.serve(w, r, next)
+		})
+	}, nil
+}
 
-			origin := r.Header.Get("Origin")
-			if origin == "" {
-				next.ServeHTTP(w, r)
-				return
+// CORSPolicy applies one policy, e.g. to a sub-router mounted with its own
+// middleware. Route-level middleware in chi doesn't see preflights for
+// methods the route lacks; prefer Options.CORSPolicies there. It panics on
+// an invalid policy.
+func CORSPolicy(c CORSOptions) func(http.Handler) http.Handler {
+	p, err := newCORSPolicy(c)
+	if err != nil {
+		panic(err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { p.serve(w, r, next) })
+	}
+}
+
+type corsPolicy struct {
+	anyOrigin bool
+	exact     map[string]bool
+	wildcards [][2]string // prefix, suffix around "*"
+	patterns  []*regexp.Regexp
+
+	methods    map[string]bool
+	anyHeader  bool
+	headers    map[string]bool
+	exposed    string
+	creds      bool
+	maxAge     string
+	privateNet bool
+}
+
+func newCORSPolicy(c CORSOptions) (*corsPolicy, error) {
+	if len(c.AllowedOrigins) == 0 && len(c.AllowedOriginPatterns) == 0 {
+		c.AllowedOrigins = []string{"*"}
+	}
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Requested-With", "X-CSRF-Token"}
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = 5 * time.Minute
+	}
+
+	p := &corsPolicy{
+		exact:      map[string]bool{},
+		methods:    map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodPost: true}, // CORS-safelisted
+		headers:    map[string]bool{},
+		exposed:    strings.Join(c.ExposedHeaders, ", "),
+		creds:      c.AllowCredentials,
+		maxAge:     strconv.Itoa(max(int(c.MaxAge.Seconds()), 0)),
+		privateNet: c.AllowPrivateNetwork,
+	}
+	for _, o := range c.AllowedOrigins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		switch {
+		case o == "*":
+			if c.AllowCredentials {
+				return nil, errors.New(`httpserver: CORS: AllowCredentials with "*" origins would let any site make credentialed requests; list the origins`)
 			}
+			p.anyOrigin = true
+		case strings.Contains(o, "*"):
+			i := strings.Index(o, "*")
+			p.wildcards = append(p.wildcards, [2]string{o[:i], o[i+1:]})
+		case o != "":
+			p.exact[o] = true
+		}
+	}
+	for _, pat := range c.AllowedOriginPatterns {
+		re, err := regexp.Compile(`^(?:` + pat + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("httpserver: CORS: bad origin pattern %q: %w", pat, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	for _, m := range c.AllowedMethods {
+		p.methods[strings.ToUpper(strings.TrimSpace(m))] = true
+	}
+	for _, h := range c.AllowedHeaders {
+		h = strings.TrimSpace(h)
+		if h == "*" {
+			p.anyHeader = true
+		}
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return p, nil
+}
 
-			// Very permissive by default; tighten by setting AllowedOrigins.
-			w.Header().Set("Access-Control-Allow-Origin", origins)
-			w.Header().Set("Access-Control-Allow-Methods", methods)
-			w.Header().Set("Access-Control-Allow-Headers", headers)
-			w.Header().Set("Access-Control-Max-Age", "300")
+func (p *corsPolicy) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h := w.Header()
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != ""
 
-			if r.Method == http.MethodOptions {
-				w.WriteHeader(http.StatusNoContent)
+	// The answer depends on Origin unless it is a constant "*".
+	if !p.anyOrigin {
+		addVary(h, "Origin")
+	}
+	if !preflight {
+		if origin != "" && p.allowOrigin(origin) {
+			p.setOrigin(h, origin)
+			if p.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	addVary(h, "Access-Control-Request-Method", "Access-Control-Request-Headers")
+	if p.privateNet {
+		addVary(h, "Access-Control-Request-Private-Network")
+	}
+	// A rejected preflight still gets 204, just without the CORS headers, so
+	// the browser blocks the request.
+	defer w.WriteHeader(http.StatusNoContent)
+
+	if !p.allowOrigin(origin) {
+		return
+	}
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !p.methods[method] {
+		return
+	}
+	reqHeaders := requestedHeaders(r)
+	if !p.anyHeader {
+		for _, rh := range reqHeaders {
+			if !p.headers[http.CanonicalHeaderKey(rh)] {
 				return
 			}
+		}
+	}
+	if r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		if !p.privateNet {
+			return
+		}
+		h.Set("Access-Control-Allow-Private-Network", "true")
+	}
 
-			next.ServeHTTP(w, r)
-		})
+	p.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", method)
+	if len(reqHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+	}
+	h.Set("Access-Control-Max-Age", p.maxAge)
+}
+
+func (p *corsPolicy) setOrigin(h http.Header, origin string) {
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if p.creds {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	o := strings.ToLower(origin)
+	if p.exact[o] {
+		return true
+	}
+	for _, wc := range p.wildcards {
+		if len(o) > len(wc[0])+len(wc[1]) && strings.HasPrefix(o, wc[0]) && strings.HasSuffix(o, wc[1]) {
+			return true
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// underPrefix reports whether path is prefix or below it ("" covers all).
+func underPrefix(path, prefix string) bool {
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// requestedHeaders splits Access-Control-Request-Headers.
+func requestedHeaders(r *http.Request) []string {
+	var out []string
+	for _, v := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				out = append(out, h)
+			}
+		}
+	}
+	return out
+}
+
+// addVary adds values to Vary unless already listed.
+func addVary(h http.Header, values ...string) {
+	have := map[string]bool{}
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			have[strings.ToLower(strings.TrimSpace(f))] = true
+		}
+	}
+	for _, v := range values {
+		if !have[strings.ToLower(v)] {
+			h.Add("Vary", v)
+			have[strings.ToLower(v)] = true
+		}
 	}
 }
@@ -0,0 +1,255 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   CORSOptions
+		origin string
+		want   bool
+	}{
+		{name: "default is any", origin: "https://anything.test", want: true},
+		{name: "exact", opts: CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}, origin: "https://app.example.com", want: true},
+		{name: "exact is case-insensitive", opts: CORSOptions{AllowedOrigins: []string{"https://App.Example.com "}}, origin: "https://app.EXAMPLE.com", want: true},
+		{name: "exact other scheme", opts: CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}, origin: "http://app.example.com", want: false},
+		{name: "exact other port", opts: CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}, origin: "https://app.example.com:8443", want: false},
+		{name: "wildcard subdomain", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "https://a.example.com", want: true},
+		{name: "wildcard nested subdomain", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "https://a.b.example.com", want: true},
+		{name: "wildcard needs a subdomain", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "https://example.com", want: false},
+		{name: "wildcard empty label", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "https://.example.com", want: false},
+		{name: "wildcard lookalike domain", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "https://evilexample.com", want: false},
+		{name: "wildcard suffix attack", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "https://a.example.com.evil.test", want: false},
+		{name: "wildcard scheme", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, origin: "http://a.example.com", want: false},
+		{name: "pattern", opts: CORSOptions{AllowedOriginPatterns: []string{`https://pr-\d+\.preview\.example\.com`}}, origin: "https://pr-42.preview.example.com", want: true},
+		{name: "pattern is anchored", opts: CORSOptions{AllowedOriginPatterns: []string{`https://pr-\d+\.preview\.example\.com`}}, origin: "https://pr-42.preview.example.com.evil.test", want: false},
+		{name: "pattern alternation is anchored", opts: CORSOptions{AllowedOriginPatterns: []string{`https://a\.test|https://b\.test`}}, origin: "https://b.test.evil", want: false},
+		{name: "patterns only exclude the default", opts: CORSOptions{AllowedOriginPatterns: []string{`https://a\.test`}}, origin: "https://b.test", want: false},
+		{name: "null origin", opts: CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}, origin: "null", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newCORSPolicy(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.allowOrigin(tt.origin); got != tt.want {
+				t.Errorf("allowOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CORSOptions
+		wantErr string
+	}{
+		{name: "credentials with any origin", opts: CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}, wantErr: "AllowCredentials"},
+		{name: "credentials with the default origins", opts: CORSOptions{AllowCredentials: true}, wantErr: "AllowCredentials"},
+		{name: "bad pattern", opts: CORSOptions{AllowedOriginPatterns: []string{`https://(`}}, wantErr: "bad origin pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newCORSPolicy(tt.opts); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newCORSPolicy() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			defer func() {
+				if recover() == nil {
+					t.Error("CORSPolicy did not panic")
+				}
+			}()
+			CORSPolicy(tt.opts)
+		})
+	}
+}
+
+func TestCORSRequests(t *testing.T) {
+	const origin = "https://app.example.com"
+	policy := CORSOptions{
+		AllowedOrigins:      []string{origin},
+		AllowedMethods:      []string{"PUT"},
+		ExposedHeaders:      []string{"X-Total"},
+		AllowCredentials:    true,
+		AllowPrivateNetwork: true,
+	}
+	tests := []struct {
+		name     string
+		opts     CORSOptions
+		method   string
+		header   map[string]string
+		code     int
+		reached  bool              // next handler ran
+		want     map[string]string // "" means absent
+		wantVary []string
+	}{
+		{
+			name: "simple request", opts: policy, method: http.MethodGet,
+			header: map[string]string{"Origin": origin},
+			code:   200, reached: true,
+			want:     map[string]string{"Access-Control-Allow-Origin": origin, "Access-Control-Allow-Credentials": "true", "Access-Control-Expose-Headers": "X-Total"},
+			wantVary: []string{"Origin"},
+		},
+		{
+			name: "simple request from another origin", opts: policy, method: http.MethodGet,
+			header: map[string]string{"Origin": "https://evil.test"},
+			code:   200, reached: true,
+			want:     map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Expose-Headers": ""},
+			wantVary: []string{"Origin"},
+		},
+		{
+			name: "no origin", opts: policy, method: http.MethodGet,
+			code: 200, reached: true,
+			want: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "preflight", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "put", "Access-Control-Request-Headers": "content-type, authorization"},
+			code:   204,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      origin,
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "PUT",
+				"Access-Control-Allow-Headers":     "content-type, authorization",
+				"Access-Control-Max-Age":           "300",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Access-Control-Request-Private-Network"},
+		},
+		{
+			name: "preflight for a safelisted method", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "POST"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Methods": "POST", "Access-Control-Allow-Headers": ""},
+		},
+		{
+			name: "preflight from another origin", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": "https://evil.test", "Access-Control-Request-Method": "PUT"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name: "preflight for a method not allowed", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "DELETE"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "preflight for a header not allowed", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "X-Custom"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "any header", opts: CORSOptions{AllowedOrigins: []string{origin}, AllowedHeaders: []string{"*"}}, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Custom"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Origin": origin, "Access-Control-Allow-Headers": "X-Custom"},
+		},
+		{
+			name: "private network", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "GET", "Access-Control-Request-Private-Network": "true"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Private-Network": "true", "Access-Control-Allow-Origin": origin},
+		},
+		{
+			name: "private network not allowed", opts: CORSOptions{AllowedOrigins: []string{origin}}, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "GET", "Access-Control-Request-Private-Network": "true"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Private-Network": "", "Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "any origin answers *", opts: CORSOptions{}, method: http.MethodOptions,
+			header: map[string]string{"Origin": "https://x.test", "Access-Control-Request-Method": "GET"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""},
+		},
+		{
+			name: "options without a request method is not a preflight", opts: policy, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin},
+			code:   200, reached: true,
+			want: map[string]string{"Access-Control-Allow-Origin": origin, "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name: "max age disabled", opts: CORSOptions{AllowedOrigins: []string{origin}, MaxAge: -1}, method: http.MethodOptions,
+			header: map[string]string{"Origin": origin, "Access-Control-Request-Method": "GET"},
+			code:   204,
+			want:   map[string]string{"Access-Control-Max-Age": "0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			h := CORSPolicy(tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				reached = true
+				w.WriteHeader(http.StatusOK)
+			}))
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			rec := serve(h, r)
+			if rec.Code != tt.code || reached != tt.reached {
+				t.Errorf("status %d, handler reached %v; want %d, %v", rec.Code, reached, tt.code, tt.reached)
+			}
+			for k, want := range tt.want {
+				if got := rec.Header().Get(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+			vary := strings.Join(rec.Header().Values("Vary"), ", ")
+			for _, v := range tt.wantVary {
+				if !strings.Contains(vary, v) {
+					t.Errorf("Vary = %q, missing %s", vary, v)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSPolicies(t *testing.T) {
+	h := CORS(Options{
+		AllowedOrigins: []string{"https://app.example.com"},
+		CORSPolicies: map[string]CORSOptions{
+			"/public/":     {},
+			"/public/auth": {AllowedOrigins: []string{"https://login.example.com"}},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	tests := []struct {
+		path   string
+		origin string
+		want   string
+	}{
+		{path: "/api", origin: "https://app.example.com", want: "https://app.example.com"},
+		{path: "/api", origin: "https://x.test", want: ""},
+		{path: "/public", origin: "https://x.test", want: "*"},
+		{path: "/public/files/a", origin: "https://x.test", want: "*"},
+		{path: "/publicity", origin: "https://x.test", want: ""}, // not under /public
+		{path: "/publicity", origin: "https://app.example.com", want: "https://app.example.com"},
+		{path: "/public/auth", origin: "https://login.example.com", want: "https://login.example.com"}, // longest prefix wins
+		{path: "/public/auth/callback", origin: "https://x.test", want: ""},
+		{path: "/public/authz", origin: "https://x.test", want: "*"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Origin", tt.origin)
+		if got := serve(h, r).Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s from %s: Access-Control-Allow-Origin = %q, want %q", tt.path, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Vary", "Accept-Encoding, origin")
+	addVary(h, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Method")
+	if got := h.Values("Vary"); len(got) != 2 || got[1] != "Access-Control-Request-Method" {
+		t.Errorf("Vary = %q", got)
+	}
+}
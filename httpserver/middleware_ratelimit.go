@@ -0,0 +1,246 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/maphash"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ranakdinesh/spur/auth/authclient"
+	"github.com/ranakdinesh/spur/logger"
+)
+
+// Rate allows Limit requests per Period, with bursts of up to Burst
+// (default Limit) requests.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// PerSecond and PerMinute are shorthands for common rates.
+func PerSecond(n int) Rate { return Rate{Limit: n, Period: time.Second} }
+func PerMinute(n int) Rate { return Rate{Limit: n, Period: time.Minute} }
+
+// Decision is a limiter's answer for one request.
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Burst: requests allowed at once
+	Remaining  int           // requests left right now
+	Reset      time.Duration // until the full burst is available again
+	RetryAfter time.Duration // when !Allowed: until a request will be allowed
+}
+
+// Limiter decides whether one more request for key fits its rate.
+// NewLocalLimiter keeps state per process; ratelimitredis.New shares it
+// across replicas.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Decision, error)
+}
+
+// KeyFunc names the bucket a request counts against; "" skips limiting.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys on the client IP. Behind a proxy it relies on
+// middleware.RealIP, which NewServer installs.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// KeyByHeader keys on a header such as an API key (hashed, so secrets don't
+// end up in Redis), falling back to the client IP.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		v := r.Header.Get(name)
+		if v == "" {
+			return KeyByIP(r)
+		}
+		sum := sha256.Sum256([]byte(v))
+		return "key:" + hex.EncodeToString(sum[:12])
+	}
+}
+
+// KeyBySubject keys on the authenticated subject (authclient.HTTPAuth),
+// falling back to the client IP.
+func KeyBySubject(r *http.Request) string {
+	if sub, ok := authclient.SubjectFrom(r.Context()); ok && sub != "" {
+		return "sub:" + sub
+	}
+	return KeyByIP(r)
+}
+
+// KeyByTenant keys on the authenticated tenant, falling back to the client IP.
+func KeyByTenant(r *http.Request) string {
+	if tid, ok := authclient.TenantIDFrom(r.Context()); ok && tid != "" {
+		return "tenant:" + tid
+	}
+	return KeyByIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr // RealIP stores a bare IP
+}
+
+type RateLimitOptions struct {
+	Limiter Limiter         // required, e.g. NewLocalLimiter(PerSecond(10))
+	Key     KeyFunc         // default KeyByIP
+	Prefix  string          // namespaces keys, e.g. per route group
+	Log     *logger.Loggerx // optional: limiter errors
+
+	// FailClosed rejects requests when the limiter errors (e.g. Redis is
+	// down); by default they are let through.
+	FailClosed bool
+}
+
+// RateLimit limits requests per key. Every response carries
+// RateLimit-Limit/-Remaining/-Reset; limited requests get a 429 with
+// Retry-After.
+func RateLimit(opt RateLimitOptions) func(http.Handler) http.Handler {
+	if opt.Key == nil {
+		opt.Key = KeyByIP
+	}
+	return func(next http.Handler) http.Handler {
+		if opt.Limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := opt.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			d, err := opt.Limiter.Allow(r.Context(), opt.Prefix+key)
+			if err != nil {
+				if opt.Log != nil {
+					opt.Log.Warn(r.Context()).Err(err).Msg("rate limiter failed")
+				}
+				if opt.FailClosed {
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "rate limiter unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(d.Reset))
+			if h.Get("Access-Control-Allow-Origin") != "" { // set by CORS, which runs first
+				addExposed(h, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After")
+			}
+			if !d.Allowed {
+				h.Set("Retry-After", ceilSeconds(d.RetryAfter))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// addExposed lets cross-origin scripts read the rate limit headers.
+func addExposed(h http.Header, names ...string) {
+	v := h.Get("Access-Control-Expose-Headers")
+	for _, n := range names {
+		if !strings.Contains(strings.ToLower(v), strings.ToLower(n)) {
+			v = strings.TrimPrefix(v+", "+n, ", ")
+		}
+	}
+	h.Set("Access-Control-Expose-Headers", v)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// GCRA (generic cell rate algorithm): each key stores only its theoretical
+// arrival time (TAT). A request is allowed if the TAT, pushed one emission
+// interval further, stays within the burst tolerance of now.
+// ratelimitredis.New runs the same arithmetic in Lua.
+
+// GCRA returns the emission interval and burst tolerance of rt.
+func (rt Rate) GCRA() (interval, tolerance time.Duration) {
+	burst := rt.Burst
+	if burst <= 0 {
+		burst = rt.Limit
+	}
+	interval = rt.Period / time.Duration(max(rt.Limit, 1))
+	return interval, interval * time.Duration(max(burst, 1))
+}
+
+// gcra applies one request at now to tat and returns the decision and the
+// new TAT (unchanged if denied).
+func gcra(rt Rate, tat, now time.Time) (Decision, time.Time) {
+	interval, tolerance := rt.GCRA()
+	limit := int(tolerance / interval)
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	if allowAt := newTAT.Add(-tolerance); now.Before(allowAt) {
+		return Decision{Limit: limit, Reset: tat.Sub(now), RetryAfter: allowAt.Sub(now)}, tat
+	}
+	return Decision{
+		Allowed:   true,
+		Limit:     limit,
+		Remaining: int((tolerance - newTAT.Sub(now)) / interval),
+		Reset:     newTAT.Sub(now),
+	}, newTAT
+}
+
+const (
+	limiterShards = 64
+	sweepEvery    = time.Minute
+)
+
+// LocalLimiter is an in-process GCRA limiter. Keys are spread over shards
+// to keep lock contention low; keys whose quota has fully recovered are
+// evicted, so memory follows the number of active clients.
+type LocalLimiter struct {
+	rate   Rate
+	seed   maphash.Seed
+	shards [limiterShards]limiterShard
+}
+
+type limiterShard struct {
+	mu    sync.Mutex
+	tats  map[string]time.Time
+	swept time.Time
+}
+
+func NewLocalLimiter(rt Rate) *LocalLimiter {
+	l := &LocalLimiter{rate: rt, seed: maphash.MakeSeed()}
+	for i := range l.shards {
+		l.shards[i].tats = map[string]time.Time{}
+	}
+	return l
+}
+
+func (l *LocalLimiter) Allow(_ context.Context, key string) (Decision, error) {
+	s := &l.shards[maphash.String(l.seed, key)%limiterShards]
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.swept) >= sweepEvery {
+		for k, tat := range s.tats {
+			if !tat.After(now) { // idle: same as a fresh key
+				delete(s.tats, k)
+			}
+		}
+		s.swept = now
+	}
+	d, tat := gcra(l.rate, s.tats[key], now)
+	s.tats[key] = tat
+	return d, nil
+}
@@ -0,0 +1,284 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ranakdinesh/spur/logger"
+)
+
+func TestRateGCRA(t *testing.T) {
+	tests := []struct {
+		rate          Rate
+		interval, tol time.Duration
+	}{
+		{rate: PerSecond(10), interval: 100 * time.Millisecond, tol: time.Second},
+		{rate: Rate{Limit: 10, Period: time.Second, Burst: 3}, interval: 100 * time.Millisecond, tol: 300 * time.Millisecond},
+		{rate: PerMinute(60), interval: time.Second, tol: time.Minute},
+		{rate: Rate{Period: time.Second}, interval: time.Second, tol: time.Second}, // Limit 0 behaves as 1
+	}
+	for _, tt := range tests {
+		if interval, tol := tt.rate.GCRA(); interval != tt.interval || tol != tt.tol {
+			t.Errorf("%+v.GCRA() = %v, %v, want %v, %v", tt.rate, interval, tol, tt.interval, tt.tol)
+		}
+	}
+}
+
+// gcraStep is a request at offset at from the start, with the decision
+// expected.
+type gcraStep struct {
+	at        time.Duration
+	allowed   bool
+	remaining int
+	reset     time.Duration
+	retry     time.Duration
+}
+
+var gcraCases = []struct {
+	name  string
+	rate  Rate
+	steps []gcraStep
+}{
+	{
+		name: "burst then steady",
+		rate: Rate{Limit: 10, Period: time.Second, Burst: 3},
+		steps: []gcraStep{
+			{at: 0, allowed: true, remaining: 2, reset: 100 * time.Millisecond},
+			{at: 0, allowed: true, remaining: 1, reset: 200 * time.Millisecond},
+			{at: 0, allowed: true, remaining: 0, reset: 300 * time.Millisecond},
+			{at: 0, allowed: false, reset: 300 * time.Millisecond, retry: 100 * time.Millisecond},
+			{at: 50 * time.Millisecond, allowed: false, reset: 250 * time.Millisecond, retry: 50 * time.Millisecond},
+			{at: 100 * time.Millisecond, allowed: true, remaining: 0, reset: 300 * time.Millisecond},
+			{at: 250 * time.Millisecond, allowed: true, remaining: 0, reset: 250 * time.Millisecond},
+		},
+	},
+	{
+		name: "full recovery",
+		rate: PerSecond(2),
+		steps: []gcraStep{
+			{at: 0, allowed: true, remaining: 1, reset: 500 * time.Millisecond},
+			{at: 0, allowed: true, remaining: 0, reset: time.Second},
+			{at: 0, allowed: false, reset: time.Second, retry: 500 * time.Millisecond},
+			{at: 5 * time.Second, allowed: true, remaining: 1, reset: 500 * time.Millisecond},
+		},
+	},
+	{
+		name: "partial recovery",
+		rate: PerMinute(4),
+		steps: []gcraStep{
+			{at: 0, allowed: true, remaining: 3, reset: 15 * time.Second},
+			{at: 0, allowed: true, remaining: 2, reset: 30 * time.Second},
+			{at: 0, allowed: true, remaining: 1, reset: 45 * time.Second},
+			{at: 0, allowed: true, remaining: 0, reset: time.Minute},
+			{at: 20 * time.Second, allowed: true, remaining: 0, reset: 55 * time.Second},
+			{at: 20 * time.Second, allowed: false, reset: 55 * time.Second, retry: 10 * time.Second},
+			{at: 40 * time.Second, allowed: true, remaining: 0, reset: 50 * time.Second},
+		},
+	},
+}
+
+func TestGCRA(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range gcraCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var tat time.Time
+			for i, s := range tt.steps {
+				var d Decision
+				d, tat = gcra(tt.rate, tat, start.Add(s.at))
+				want := Decision{Allowed: s.allowed, Limit: d.Limit, Remaining: s.remaining, Reset: s.reset, RetryAfter: s.retry}
+				if d != want {
+					t.Errorf("step %d at %v: got %+v, want %+v", i, s.at, d, want)
+				}
+			}
+		})
+	}
+}
+
+// errLimiter always fails, like a limiter whose Redis is down.
+type errLimiter struct{}
+
+func (errLimiter) Allow(context.Context, string) (Decision, error) {
+	return Decision{}, errors.New("connection refused")
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	tests := []struct {
+		name  string
+		opts  RateLimitOptions
+		reqs  []string // RemoteAddr per request
+		codes []int
+		last  map[string]string // headers of the last response
+	}{
+		{
+			name:  "limited per ip",
+			opts:  RateLimitOptions{Limiter: NewLocalLimiter(PerMinute(2))},
+			reqs:  []string{"10.0.0.1:1", "10.0.0.1:2", "10.0.0.2:1", "10.0.0.1:3"},
+			codes: []int{200, 200, 200, 429},
+			last:  map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", "Retry-After": "30"},
+		},
+		{
+			name:  "headers on allowed requests",
+			opts:  RateLimitOptions{Limiter: NewLocalLimiter(Rate{Limit: 10, Period: time.Second, Burst: 5})},
+			reqs:  []string{"10.0.0.1:1", "10.0.0.1:1"},
+			codes: []int{200, 200},
+			last:  map[string]string{"RateLimit-Limit": "5", "RateLimit-Remaining": "3", "RateLimit-Reset": "1", "Retry-After": ""},
+		},
+		{
+			name:  "empty key skips limiting",
+			opts:  RateLimitOptions{Limiter: NewLocalLimiter(PerMinute(1)), Key: func(*http.Request) string { return "" }},
+			reqs:  []string{"10.0.0.1:1", "10.0.0.1:1"},
+			codes: []int{200, 200},
+			last:  map[string]string{"RateLimit-Limit": ""},
+		},
+		{
+			name:  "nil limiter",
+			opts:  RateLimitOptions{},
+			reqs:  []string{"10.0.0.1:1", "10.0.0.1:1"},
+			codes: []int{200, 200},
+		},
+		{
+			name:  "fail open",
+			opts:  RateLimitOptions{Limiter: errLimiter{}, Log: logger.NewWithWriter(io.Discard, logger.Options{})},
+			reqs:  []string{"10.0.0.1:1"},
+			codes: []int{200},
+			last:  map[string]string{"RateLimit-Limit": ""},
+		},
+		{
+			name:  "fail closed",
+			opts:  RateLimitOptions{Limiter: errLimiter{}, FailClosed: true},
+			reqs:  []string{"10.0.0.1:1"},
+			codes: []int{503},
+			last:  map[string]string{"Retry-After": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := RateLimit(tt.opts)(ok)
+			var rec *httptest.ResponseRecorder
+			for i, addr := range tt.reqs {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = addr
+				rec = serve(h, r)
+				if rec.Code != tt.codes[i] {
+					t.Errorf("request %d from %s: status %d, want %d", i, addr, rec.Code, tt.codes[i])
+				}
+			}
+			for k, want := range tt.last {
+				if got := rec.Header().Get(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:4321"
+	withKey := r.Clone(context.Background())
+	withKey.Header.Set("X-Api-Key", "secret")
+
+	tests := []struct {
+		name string
+		key  KeyFunc
+		r    *http.Request
+		want string
+	}{
+		{name: "ip", key: KeyByIP, r: r, want: "ip:192.0.2.1"},
+		{name: "header falls back to ip", key: KeyByHeader("X-Api-Key"), r: r, want: "ip:192.0.2.1"},
+		{name: "header is hashed", key: KeyByHeader("X-Api-Key"), r: withKey, want: "key:2bb80d537b1da3e38bd30361"},
+		{name: "subject falls back to ip", key: KeyBySubject, r: r, want: "ip:192.0.2.1"},
+		{name: "tenant falls back to ip", key: KeyByTenant, r: r, want: "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		if got := tt.key(tt.r); got != tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitBehindCORS(t *testing.T) {
+	srv := NewServer(Options{
+		EnableCORS:     true,
+		AllowedOrigins: []string{"https://app.example.com"},
+		ExposedHeaders: []string{"X-Total"},
+		RateLimit:      &RateLimitOptions{Limiter: NewLocalLimiter(PerMinute(1))},
+	}, logger.NewWithWriter(io.Discard, logger.Options{}), nil)
+	h := srv.http.Handler
+
+	req := func(method string) *http.Request {
+		r := httptest.NewRequest(method, "/healthz", nil)
+		r.RemoteAddr = "10.0.0.1:1"
+		r.Header.Set("Origin", "https://app.example.com")
+		if method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		return r
+	}
+
+	// Preflights are answered before the limiter and not counted.
+	for range 3 {
+		if rec := serve(h, req(http.MethodOptions)); rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("preflight: status %d, RateLimit-Limit %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+	if rec := serve(h, req(http.MethodGet)); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+
+	// The 429 carries the CORS headers, so the browser lets the script see it.
+	rec := serve(h, req(http.MethodGet))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	exposed := rec.Header().Get("Access-Control-Expose-Headers")
+	for _, name := range []string{"X-Total", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"} {
+		if !strings.Contains(exposed, name) {
+			t.Errorf("Access-Control-Expose-Headers = %q, missing %s", exposed, name)
+		}
+	}
+
+	// Without an allowed origin nothing is exposed.
+	r := req(http.MethodGet)
+	r.Header.Set("Origin", "https://evil.example.org")
+	r.RemoteAddr = "10.0.0.2:1"
+	if rec := serve(h, r); rec.Header().Get("Access-Control-Expose-Headers") != "" {
+		t.Errorf("exposed headers for a foreign origin: %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
+
+func TestAddExposed(t *testing.T) {
+	tests := []struct {
+		have, want string
+	}{
+		{have: "", want: "RateLimit-Limit, Retry-After"},
+		{have: "X-Total", want: "X-Total, RateLimit-Limit, Retry-After"},
+		{have: "retry-after", want: "retry-after, RateLimit-Limit"},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.have != "" {
+			h.Set("Access-Control-Expose-Headers", tt.have)
+		}
+		addExposed(h, "RateLimit-Limit", "Retry-After")
+		if got := h.Get("Access-Control-Expose-Headers"); got != tt.want {
+			t.Errorf("addExposed(%q) = %q, want %q", tt.have, got, tt.want)
+		}
+	}
+}
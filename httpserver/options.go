@@ -16,14 +16,46 @@ type Options struct {
 	WriteTimeout      time.Duration
 	IdleTimeout       time.Duration
 
+	// TLS: serve HTTPS when both files are set. They are re-read when they
+	// change on disk (e.g. cert-manager rotation), no restart needed.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// mTLS: verify client certificates against ClientCAFile. ClientAuth is
+	// "require" (default when ClientCAFile is set) or "verify_if_given". The
+	// identity of a verified client is in ClientIdentityFrom(r.Context()).
+	ClientCAFile string
+	ClientAuth   string
+
+	TLSMinVersion   string   // "1.2" (default) or "1.3"
+	TLSCipherSuites []string // TLS 1.2 suites by name, e.g. "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"; nil => Go's secure defaults
+
+	// Graceful shutdown: when Start's ctx is canceled /readyz turns 503 and the
+	// server keeps serving for PreStopDelay so the load balancer can deregister
+	// it, then drains in-flight requests and runs the OnShutdown hooks, all
+	// within ShutdownTimeout (default 10s).
+	PreStopDelay    time.Duration
+	ShutdownTimeout time.Duration
+
 	// Max request body size in bytes (0 = unlimited; recommended: 10<<20 for 10MB)
 	MaxBodyBytes int64
 
-	// CORS
-	EnableCORS     bool
-	AllowedOrigins []string // nil => ["*"]
-	AllowedMethods []string // nil => GET,POST,PUT,PATCH,DELETE,OPTIONS
-	AllowedHeaders []string // nil => common headers
+	// CORS (see CORSOptions for the matching rules)
+	EnableCORS            bool
+	AllowedOrigins        []string // exact, "https://*.example.com" or "*"; nil => ["*"]
+	AllowedOriginPatterns []string // regexes matched against the whole origin
+	AllowedMethods        []string // nil => GET,POST,PUT,PATCH,DELETE,OPTIONS
+	AllowedHeaders        []string // nil => common headers
+	ExposedHeaders        []string
+	AllowCredentials      bool
+	CORSMaxAge            time.Duration // preflight cache; default 5m
+	AllowPrivateNetwork   bool
+	CORSPolicies          map[string]CORSOptions // path prefix -> policy replacing the above
+
+	// Rate limiting for every route (optional). Keys from authclient
+	// (KeyBySubject, KeyByTenant) need the auth middleware to run first, so
+	// use RateLimit(...) on the protected group for those.
+	RateLimit *RateLimitOptions
 
 	// Security headers (on by default unless explicitly disabled)
 	EnableSecurityHeaders bool
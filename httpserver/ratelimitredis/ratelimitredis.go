@@ -0,0 +1,69 @@
+// Package ratelimitredis is an httpserver.Limiter backed by Redis, so a limit
+// holds across all replicas.
+package ratelimitredis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ranakdinesh/spur/httpserver"
+)
+
+// gcraScript is httpserver's GCRA on Redis time, in microseconds. The key
+// holds the TAT and expires once the quota has fully recovered.
+// Returns {allowed, remaining, reset_us, retry_after_us}.
+var gcraScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then tat = now end
+local new = tat + interval
+local allow_at = new - tolerance
+if now < allow_at then
+	return {0, 0, tat - now, allow_at - now}
+end
+redis.call("SET", KEYS[1], new, "PX", math.ceil((new - now) / 1000))
+return {1, math.floor((tolerance - (new - now)) / interval), new - now, 0}`)
+
+// Limiter runs GCRA in Redis. Each key costs one small string that expires
+// when idle.
+type Limiter struct {
+	rdb    *redis.Client
+	rate   httpserver.Rate
+	prefix string
+}
+
+// New returns a limiter on rdb (e.g. from rediskit.NewClient); prefix
+// defaults to "ratelimit:".
+func New(rdb *redis.Client, rate httpserver.Rate, prefix string) *Limiter {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &Limiter{rdb: rdb, rate: rate, prefix: prefix}
+}
+
+func (l *Limiter) Allow(ctx context.Context, key string) (httpserver.Decision, error) {
+	if l.rdb == nil {
+		return httpserver.Decision{}, fmt.Errorf("ratelimitredis: nil client")
+	}
+	interval, tolerance := l.rate.GCRA()
+	res, err := gcraScript.Run(ctx, l.rdb, []string{l.prefix + key}, interval.Microseconds(), tolerance.Microseconds()).Int64Slice()
+	if err != nil {
+		return httpserver.Decision{}, fmt.Errorf("ratelimitredis: %w", err)
+	}
+	if len(res) != 4 {
+		return httpserver.Decision{}, fmt.Errorf("ratelimitredis: unexpected reply %v", res)
+	}
+	return httpserver.Decision{
+		Allowed:    res[0] == 1,
+		Limit:      int(tolerance / interval),
+		Remaining:  int(res[1]),
+		Reset:      time.Duration(res[2]) * time.Microsecond,
+		RetryAfter: time.Duration(res[3]) * time.Microsecond,
+	}, nil
+}
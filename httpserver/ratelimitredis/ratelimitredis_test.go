@@ -0,0 +1,159 @@
+package ratelimitredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ranakdinesh/spur/httpserver"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+func TestLimiterScript(t *testing.T) {
+	type step struct {
+		at        time.Duration // since the start, on the Redis clock
+		allowed   bool
+		remaining int
+		reset     time.Duration
+		retry     time.Duration
+	}
+	// The same sequences as httpserver's GCRA tests: the script must agree
+	// with the local arithmetic.
+	tests := []struct {
+		name  string
+		rate  httpserver.Rate
+		steps []step
+	}{
+		{
+			name: "burst then steady",
+			rate: httpserver.Rate{Limit: 10, Period: time.Second, Burst: 3},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 2, reset: 100 * time.Millisecond},
+				{at: 0, allowed: true, remaining: 1, reset: 200 * time.Millisecond},
+				{at: 0, allowed: true, remaining: 0, reset: 300 * time.Millisecond},
+				{at: 0, allowed: false, reset: 300 * time.Millisecond, retry: 100 * time.Millisecond},
+				{at: 50 * time.Millisecond, allowed: false, reset: 250 * time.Millisecond, retry: 50 * time.Millisecond},
+				{at: 100 * time.Millisecond, allowed: true, remaining: 0, reset: 300 * time.Millisecond},
+				{at: 250 * time.Millisecond, allowed: true, remaining: 0, reset: 250 * time.Millisecond},
+			},
+		},
+		{
+			name: "full recovery",
+			rate: httpserver.PerSecond(2),
+			steps: []step{
+				{at: 0, allowed: true, remaining: 1, reset: 500 * time.Millisecond},
+				{at: 0, allowed: true, remaining: 0, reset: time.Second},
+				{at: 0, allowed: false, reset: time.Second, retry: 500 * time.Millisecond},
+				{at: 5 * time.Second, allowed: true, remaining: 1, reset: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "partial recovery",
+			rate: httpserver.PerMinute(4),
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3, reset: 15 * time.Second},
+				{at: 0, allowed: true, remaining: 2, reset: 30 * time.Second},
+				{at: 0, allowed: true, remaining: 1, reset: 45 * time.Second},
+				{at: 0, allowed: true, remaining: 0, reset: time.Minute},
+				{at: 20 * time.Second, allowed: true, remaining: 0, reset: 55 * time.Second},
+				{at: 20 * time.Second, allowed: false, reset: 55 * time.Second, retry: 10 * time.Second},
+				{at: 40 * time.Second, allowed: true, remaining: 0, reset: 50 * time.Second},
+			},
+		},
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, rdb := newTestRedis(t)
+			l := New(rdb, tt.rate, "")
+			_, tol := tt.rate.GCRA()
+			var last time.Duration
+			for i, s := range tt.steps {
+				// TIME in the script follows SetTime; TTLs follow FastForward.
+				mr.SetTime(start.Add(s.at))
+				mr.FastForward(s.at - last)
+				last = s.at
+
+				d, err := l.Allow(t.Context(), "client")
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := httpserver.Decision{Allowed: s.allowed, Limit: d.Limit, Remaining: s.remaining, Reset: s.reset, RetryAfter: s.retry}
+				if d != want {
+					t.Errorf("step %d at %v: got %+v, want %+v", i, s.at, d, want)
+				}
+				if ttl := mr.TTL("ratelimit:client"); ttl <= 0 || ttl > tol {
+					t.Errorf("step %d: TTL %v, want (0, %v]", i, ttl, tol)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeyExpires(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	l := New(rdb, httpserver.PerSecond(2), "rl:")
+	for range 2 {
+		if _, err := l.Allow(t.Context(), "k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !mr.Exists("rl:k") {
+		t.Fatal("no key under the prefix")
+	}
+	// Once the quota has fully recovered the key is gone, same as a new one.
+	mr.FastForward(time.Second)
+	if mr.Exists("rl:k") {
+		t.Error("key still there after full recovery")
+	}
+}
+
+func TestLimiterMatchesLocal(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	rate := httpserver.Rate{Limit: 5, Period: time.Minute, Burst: 3}
+	remote, local := New(rdb, rate, ""), httpserver.NewLocalLimiter(rate)
+	for i := range 5 {
+		rd, err := remote.Allow(t.Context(), "k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ld, _ := local.Allow(t.Context(), "k")
+		if rd.Allowed != ld.Allowed || rd.Limit != ld.Limit || rd.Remaining != ld.Remaining ||
+			(rd.Reset-ld.Reset).Abs() > time.Second || (rd.RetryAfter-ld.RetryAfter).Abs() > time.Second {
+			t.Errorf("request %d: redis %+v, local %+v", i, rd, ld)
+		}
+	}
+}
+
+func TestLimiterErrors(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	down := New(rdb, httpserver.PerSecond(1), "")
+	mr.Close()
+
+	tests := []struct {
+		name string
+		l    *Limiter
+	}{
+		{name: "nil client", l: New(nil, httpserver.PerSecond(1), "")},
+		{name: "redis down", l: down},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			defer cancel()
+			if _, err := tt.l.Allow(ctx, "k"); err == nil {
+				t.Error("Allow() = nil error")
+			}
+		})
+	}
+}
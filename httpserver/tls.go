@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ranakdinesh/spur/logger"
+)
+
+// certCheckInterval is how often handshakes look for rotated files on disk.
+const certCheckInterval = 10 * time.Second
+
+type ctxKey string
+
+const ctxClientIdentity ctxKey = "httpserver.client_identity"
+
+// ClientIdentityFrom returns the identity of a verified client certificate:
+// its first URI SAN (e.g. a SPIFFE ID), else its subject CN.
+func ClientIdentityFrom(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(ctxClientIdentity).(string)
+	return v, ok
+}
+
+// ClientIdentity puts the verified client certificate's identity into the
+// request context (see ClientIdentityFrom). NewServer installs it.
+func ClientIdentity() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+				if id := certIdentity(r.TLS.VerifiedChains[0][0]); id != "" {
+					r = r.WithContext(context.WithValue(r.Context(), ctxClientIdentity, id))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func certIdentity(c *x509.Certificate) string {
+	if len(c.URIs) > 0 {
+		return c.URIs[0].String()
+	}
+	return c.Subject.CommonName
+}
+
+// newTLSConfig builds the server TLS config from opts, or nil if TLS is off.
+func newTLSConfig(opts Options, log *logger.Loggerx) (*tls.Config, error) {
+	if opts.TLSCertFile == "" && opts.TLSKeyFile == "" {
+		if opts.ClientCAFile != "" {
+			return nil, fmt.Errorf("httpserver: ClientCAFile needs TLSCertFile and TLSKeyFile")
+		}
+		return nil, nil
+	}
+	if opts.TLSCertFile == "" || opts.TLSKeyFile == "" {
+		return nil, fmt.Errorf("httpserver: TLSCertFile and TLSKeyFile must be set together")
+	}
+
+	base := &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	switch opts.TLSMinVersion {
+	case "", "1.2":
+		base.MinVersion = tls.VersionTLS12
+	case "1.3":
+		base.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("httpserver: unsupported TLSMinVersion %q (want 1.2 or 1.3)", opts.TLSMinVersion)
+	}
+	if len(opts.TLSCipherSuites) > 0 {
+		ids, err := cipherSuites(opts.TLSCipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		base.CipherSuites = ids
+	}
+	switch opts.ClientAuth {
+	case "":
+		if opts.ClientCAFile != "" {
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	case "none":
+	case "require":
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	case "verify_if_given":
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("httpserver: unknown ClientAuth %q (want require or verify_if_given)", opts.ClientAuth)
+	}
+	if base.ClientAuth != tls.NoClientCert && opts.ClientCAFile == "" {
+		return nil, fmt.Errorf("httpserver: ClientAuth %q needs ClientCAFile", opts.ClientAuth)
+	}
+
+	cr := &certReloader{base: base, certFile: opts.TLSCertFile, keyFile: opts.TLSKeyFile, caFile: opts.ClientCAFile, log: log}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         base.MinVersion,
+		NextProtos:         base.NextProtos,
+		GetConfigForClient: cr.configForClient,
+	}, nil
+}
+
+// cipherSuites maps names (as in tls.CipherSuiteName) to IDs. Only Go's
+// secure suites are accepted; TLS 1.3 suites aren't configurable.
+func cipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, n := range names {
+		id, ok := known[strings.TrimSpace(n)]
+		if !ok {
+			return nil, fmt.Errorf("httpserver: unknown or insecure cipher suite %q", n)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certReloader serves the key pair and client CAs from disk and reloads them
+// when a file changes, e.g. after cert-manager rotated a mounted secret. A
+// broken update (half-written files, key mismatch) keeps the previous ones.
+type certReloader struct {
+	base                      *tls.Config
+	certFile, keyFile, caFile string
+	log                       *logger.Loggerx
+
+	mu      sync.RWMutex
+	cfg     *tls.Config // base plus the current certificate and CA pool
+	mtimes  [3]time.Time
+	checked time.Time
+}
+
+func (c *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.mu.RLock()
+	cfg, due := c.cfg, time.Since(c.checked) >= certCheckInterval
+	c.mu.RUnlock()
+	if !due {
+		return cfg, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked) < certCheckInterval { // another handshake got here first
+		return c.cfg, nil
+	}
+	c.checked = time.Now()
+	if c.modTimes() != c.mtimes {
+		if err := c.loadLocked(); err != nil {
+			c.log.Warn(context.Background()).Err(err).Msg("http server: tls reload failed, keeping the current certificate")
+		} else {
+			c.log.Info(context.Background()).Str("cert", c.certFile).Msg("http server: tls certificate reloaded")
+		}
+	}
+	return c.cfg, nil
+}
+
+func (c *certReloader) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = time.Now()
+	return c.loadLocked()
+}
+
+func (c *certReloader) loadLocked() error {
+	mtimes := c.modTimes()
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("httpserver: tls: %w", err)
+	}
+	cfg := c.base.Clone()
+	cfg.Certificates = []tls.Certificate{cert}
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return fmt.Errorf("httpserver: tls: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("httpserver: tls: no certificates in %s", c.caFile)
+		}
+		cfg.ClientCAs = pool
+	}
+	c.cfg, c.mtimes = cfg, mtimes
+	return nil
+}
+
+// modTimes stats the files (following symlinks, as Kubernetes secret volumes
+// swap them on update).
+func (c *certReloader) modTimes() [3]time.Time {
+	var out [3]time.Time
+	for i, f := range []string{c.certFile, c.keyFile, c.caFile} {
+		if f == "" {
+			continue
+		}
+		if st, err := os.Stat(f); err == nil {
+			out[i] = st.ModTime()
+		}
+	}
+	return out
+}
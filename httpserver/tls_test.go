@@ -0,0 +1,275 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ranakdinesh/spur/logger"
+)
+
+// testCert is a certificate with its key, signed by parent (self-signed if
+// parent is nil).
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, serial int64, parent *testCert, tmpl x509.Certificate) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(serial)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := &tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func newTestCA(t *testing.T) *testCert {
+	return newTestCert(t, 1, nil, x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+}
+
+func newServerCert(t *testing.T, serial int64, ca *testCert) *testCert {
+	return newTestCert(t, serial, ca, x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+// write stores the certificate and key as PEM files and bumps their mtime
+// to at, so a reload sees a change however coarse the file system clock.
+func (c *testCert) write(t *testing.T, certFile, keyFile string, at time.Time) {
+	t.Helper()
+	kb, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFileAt(t, certFile, c.certPEM(), at)
+	writeFileAt(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), at)
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func writeFileAt(t *testing.T, name string, data []byte, at time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	newServerCert(t, 2, ca).write(t, certFile, keyFile, time.Now())
+	writeFileAt(t, caFile, ca.certPEM(), time.Now())
+	notPEM := filepath.Join(dir, "empty.crt")
+	writeFileAt(t, notPEM, []byte("nothing here"), time.Now())
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string // "" for a valid config
+	}{
+		{name: "off", opts: Options{}},
+		{name: "valid", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile}},
+		{name: "valid mtls", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile, ClientCAFile: caFile, TLSMinVersion: "1.3"}},
+		{name: "cert without key", opts: Options{TLSCertFile: certFile}, wantErr: "must be set together"},
+		{name: "client ca without tls", opts: Options{ClientCAFile: caFile}, wantErr: "needs TLSCertFile"},
+		{name: "min version", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.1"}, wantErr: "unsupported TLSMinVersion"},
+		{name: "insecure suite", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, wantErr: "cipher suite"},
+		{name: "client auth", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "optional"}, wantErr: "unknown ClientAuth"},
+		{name: "client auth without ca", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile, ClientAuth: "require"}, wantErr: "needs ClientCAFile"},
+		{name: "missing key file", opts: Options{TLSCertFile: certFile, TLSKeyFile: filepath.Join(dir, "nope")}, wantErr: "httpserver: tls"},
+		{name: "ca without certificates", opts: Options{TLSCertFile: certFile, TLSKeyFile: keyFile, ClientCAFile: notPEM}, wantErr: "no certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTLSConfig(tt.opts, logger.NewWithWriter(io.Discard, logger.Options{}))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("newTLSConfig() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("newTLSConfig() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Minute)
+	newServerCert(t, 2, ca).write(t, certFile, keyFile, start)
+
+	cr := &certReloader{base: &tls.Config{}, certFile: certFile, keyFile: keyFile, log: logger.NewWithWriter(io.Discard, logger.Options{})}
+	if err := cr.load(); err != nil {
+		t.Fatal(err)
+	}
+	serial := func() int64 {
+		t.Helper()
+		cfg, err := cr.configForClient(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+	due := func() {
+		cr.mu.Lock()
+		cr.checked = time.Time{}
+		cr.mu.Unlock()
+	}
+
+	tests := []struct {
+		name   string
+		update func(at time.Time)
+		due    bool
+		want   int64
+	}{
+		{name: "unchanged", update: func(time.Time) {}, due: true, want: 2},
+		{name: "rotated before the check interval", update: func(at time.Time) { newServerCert(t, 3, ca).write(t, certFile, keyFile, at) }, want: 2},
+		{name: "picked up once due", update: func(time.Time) {}, due: true, want: 3},
+		{name: "broken update keeps the current one", update: func(at time.Time) { writeFileAt(t, keyFile, []byte("half-written"), at) }, due: true, want: 3},
+		{name: "mismatched pair keeps the current one", update: func(at time.Time) {
+			newServerCert(t, 4, ca).write(t, certFile, keyFile, at)
+			other := newServerCert(t, 5, ca)
+			kb, _ := x509.MarshalECPrivateKey(other.key)
+			writeFileAt(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), at)
+		}, due: true, want: 3},
+		{name: "fixed update", update: func(at time.Time) { newServerCert(t, 6, ca).write(t, certFile, keyFile, at) }, due: true, want: 6},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update(start.Add(time.Duration(i+1) * time.Second))
+			if tt.due {
+				due()
+			}
+			if got := serial(); got != tt.want {
+				t.Errorf("serving serial %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerMTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	newServerCert(t, 2, ca).write(t, certFile, keyFile, time.Now())
+	writeFileAt(t, caFile, ca.certPEM(), time.Now())
+
+	spiffe, _ := url.Parse("spiffe://example.org/ns/default/sa/orders")
+	withURI := newTestCert(t, 10, ca, x509.Certificate{
+		Subject:     pkix.Name{CommonName: "orders"},
+		URIs:        []*url.URL{spiffe},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	withCN := newTestCert(t, 11, ca, x509.Certificate{
+		Subject:     pkix.Name{CommonName: "billing"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	untrusted := newTestCert(t, 12, newTestCA(t), x509.Certificate{
+		Subject:     pkix.Name{CommonName: "mallory"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	for _, mode := range []string{"require", "verify_if_given"} {
+		t.Run(mode, func(t *testing.T) {
+			_, addr, _, _ := startServer(t, Options{TLSCertFile: certFile, TLSKeyFile: keyFile, ClientCAFile: caFile, ClientAuth: mode}, func(r chi.Router) {
+				r.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+					id, _ := ClientIdentityFrom(r.Context())
+					_, _ = io.WriteString(w, id)
+				})
+			})
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.cert)
+
+			tests := []struct {
+				name   string
+				client *testCert
+				want   string // identity, or "error" if the handshake must fail
+			}{
+				{name: "uri san", client: withURI, want: spiffe.String()},
+				{name: "common name", client: withCN, want: "billing"},
+				{name: "untrusted", client: untrusted, want: "error"},
+				{name: "no certificate", want: map[string]string{"require": "error", "verify_if_given": ""}[mode]},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					cfg := &tls.Config{RootCAs: roots}
+					if tt.client != nil {
+						cfg.Certificates = []tls.Certificate{tt.client.tlsCert()}
+					}
+					client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+					defer client.CloseIdleConnections()
+					resp, err := client.Get("https://" + addr + "/whoami")
+					if tt.want == "error" {
+						if err == nil {
+							resp.Body.Close()
+							t.Fatalf("request succeeded with status %d", resp.StatusCode)
+						}
+						return
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer resp.Body.Close()
+					body, _ := io.ReadAll(resp.Body)
+					if string(body) != tt.want {
+						t.Errorf("identity = %q, want %q", body, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
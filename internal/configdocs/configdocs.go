@@ -0,0 +1,538 @@
+// Package configdocs generates .env.example, a markdown reference and
+// Kubernetes ConfigMap/Secret manifests from the structs a package loads with
+// spur's config package, so they stay in sync with the code.
+package configdocs
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ranakdinesh/spur/config"
+)
+
+const configImport = "github.com/ranakdinesh/spur/config"
+
+// Options controls Generate.
+type Options struct {
+	Dir  string // package to scan (default ".")
+	Name string // service name used in k8s manifests (default: base of cwd)
+
+	// Output paths; empty skips that output.
+	EnvFile  string // e.g. ".env.example"
+	Markdown string // e.g. "CONFIG.md"
+	K8sDir   string // writes configmap.yaml and secret.yaml here
+
+	// Force overwrites existing k8s manifests. Without it Generate refuses,
+	// since they may hold filled-in secrets or per-environment values.
+	Force bool
+}
+
+// Var is one env variable found in a config struct.
+type Var struct {
+	Env      string
+	Type     string
+	Default  string
+	Desc     string
+	Required bool
+	Secret   bool
+}
+
+// Generate scans opt.Dir and writes the requested outputs.
+func Generate(opt Options) error {
+	if opt.Dir == "" {
+		opt.Dir = "."
+	}
+	if opt.Name == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		opt.Name = filepath.Base(wd)
+	}
+	vars, err := Scan(opt.Dir)
+	if err != nil {
+		return err
+	}
+	if len(vars) == 0 {
+		return fmt.Errorf("no config.Load calls found in %s", opt.Dir)
+	}
+	if opt.EnvFile != "" {
+		if err := write(opt.EnvFile, EnvExample(vars)); err != nil {
+			return err
+		}
+	}
+	if opt.Markdown != "" {
+		if err := write(opt.Markdown, Markdown(vars)); err != nil {
+			return err
+		}
+	}
+	if opt.K8sDir != "" {
+		cm, sec := Manifests(opt.Name, vars)
+		if !opt.Force {
+			for _, f := range []string{"configmap.yaml", "secret.yaml"} {
+				if _, err := os.Stat(filepath.Join(opt.K8sDir, f)); err == nil {
+					return fmt.Errorf("%s exists; pass --force to overwrite it", filepath.Join(opt.K8sDir, f))
+				}
+			}
+		}
+		if err := write(filepath.Join(opt.K8sDir, "configmap.yaml"), cm); err != nil {
+			return err
+		}
+		if err := write(filepath.Join(opt.K8sDir, "secret.yaml"), sec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scan finds every struct passed to config.Load, MustLoad, LoadWithOptions, LoadOrigins or
+// Watch[T] in the package at dir and returns its env variables in field order.
+func Scan(dir string) ([]Var, error) {
+	s := &scanner{pkgs: map[string]*pkg{}}
+	p, err := s.parse(dir)
+	if err != nil {
+		return nil, err
+	}
+	var vars []Var
+	seen := map[string]bool{}
+	for _, root := range p.roots() {
+		ref, ok := p.structs[root]
+		if !ok {
+			continue
+		}
+		var vs []Var
+		if err := s.walk(ref, "", &vs, 0); err != nil {
+			return nil, err
+		}
+		for _, v := range vs {
+			if !seen[v.Env] {
+				seen[v.Env] = true
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars, nil
+}
+
+// ---------- Renderers ----------
+
+// EnvExample renders a .env.example; secrets are left blank.
+func EnvExample(vars []Var) string {
+	var b strings.Builder
+	b.WriteString("# Generated by `spur config docs`. Do not edit by hand.\n")
+	for _, v := range vars {
+		b.WriteString("\n")
+		if c := comment(v); c != "" {
+			fmt.Fprintf(&b, "# %s\n", c)
+		}
+		val := v.Default
+		if v.Secret {
+			val = ""
+		}
+		fmt.Fprintf(&b, "%s=%s\n", v.Env, val)
+	}
+	return b.String()
+}
+
+// Markdown renders a reference table.
+func Markdown(vars []Var) string {
+	var b strings.Builder
+	b.WriteString("# Configuration\n\n")
+	b.WriteString("Generated by `spur config docs`. Do not edit by hand.\n\n")
+	b.WriteString("| Env | Type | Default | Required | Secret | Description |\n")
+	b.WriteString("|-----|------|---------|----------|--------|-------------|\n")
+	for _, v := range vars {
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %s | %s |\n",
+			v.Env, v.Type, code(v.Default), yesNo(v.Required), yesNo(v.Secret), cell(v.Desc))
+	}
+	return b.String()
+}
+
+// Manifests renders a ConfigMap (plain values with their defaults) and a
+// Secret (secret values, blank) for the k8s base.
+func Manifests(name string, vars []Var) (configMap, secret string) {
+	var cm, sec strings.Builder
+	fmt.Fprintf(&cm, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s-config\ndata:\n", name)
+	fmt.Fprintf(&sec, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: %s-secret\ntype: Opaque\nstringData:\n", name)
+	for _, v := range vars {
+		if v.Secret {
+			fmt.Fprintf(&sec, "  %s: \"\"\n", v.Env)
+			continue
+		}
+		fmt.Fprintf(&cm, "  %s: %s\n", v.Env, strconv.Quote(v.Default))
+	}
+	return cm.String(), sec.String()
+}
+
+func comment(v Var) string {
+	parts := make([]string, 0, 3)
+	if v.Desc != "" {
+		parts = append(parts, v.Desc)
+	}
+	if v.Required {
+		parts = append(parts, "(required)")
+	}
+	if v.Secret {
+		parts = append(parts, "(secret)")
+	}
+	return strings.Join(parts, " ")
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
+
+func cell(s string) string { return strings.ReplaceAll(s, "|", "\\|") }
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return ""
+}
+
+func write(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// ---------- Source scanning ----------
+
+type pkg struct {
+	dir     string
+	files   []*ast.File
+	structs map[string]structRef
+	text    map[string]bool // types with an UnmarshalText method (leaves)
+}
+
+type structRef struct {
+	st   *ast.StructType
+	file *ast.File
+	pkg  *pkg
+}
+
+type scanner struct {
+	fset *token.FileSet
+	pkgs map[string]*pkg // by dir
+}
+
+func (s *scanner) parse(dir string) (*pkg, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := s.pkgs[dir]; ok {
+		return p, nil
+	}
+	if s.fset == nil {
+		s.fset = token.NewFileSet()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := &pkg{dir: dir, structs: map[string]structRef{}, text: map[string]bool{}}
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !strings.HasSuffix(n, ".go") || strings.HasSuffix(n, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(s.fset, filepath.Join(dir, n), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, f)
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if st, ok := ts.Type.(*ast.StructType); ok {
+							p.structs[ts.Name.Name] = structRef{st: st, file: f, pkg: p}
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Name.Name == "UnmarshalText" && d.Recv != nil && len(d.Recv.List) == 1 {
+					p.text[recvName(d.Recv.List[0].Type)] = true
+				}
+			}
+		}
+	}
+	s.pkgs[dir] = p
+	return p, nil
+}
+
+// roots returns the type names passed to the config loaders, in source order.
+func (p *pkg) roots() []string {
+	var out []string
+	for _, f := range p.files {
+		alias := importName(f, configImport)
+		if alias == "" {
+			continue
+		}
+		vars := varTypes(f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			switch fn := call.Fun.(type) {
+			case *ast.SelectorExpr:
+				if !isPkg(fn.X, alias) || len(call.Args) == 0 {
+					return true
+				}
+				switch fn.Sel.Name {
+				case "Load", "MustLoad", "LoadWithOptions", "LoadOrigins":
+					if t := argType(call.Args[0], vars); t != "" {
+						out = append(out, t)
+					}
+				}
+			case *ast.IndexExpr:
+				if sel, ok := fn.X.(*ast.SelectorExpr); ok && isPkg(sel.X, alias) && sel.Sel.Name == "Watch" {
+					if id, ok := fn.Index.(*ast.Ident); ok {
+						out = append(out, id.Name)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return out
+}
+
+// walk appends the leaf fields of ref to out, mirroring config.Load's rules
+// for envPrefix, embedded and pointer structs.
+func (s *scanner) walk(ref structRef, prefix string, out *[]Var, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("config struct nesting too deep (recursive type?)")
+	}
+	for _, fld := range ref.st.Fields.List {
+		var tag reflect.StructTag
+		if fld.Tag != nil {
+			raw, err := strconv.Unquote(fld.Tag.Value)
+			if err != nil {
+				return err
+			}
+			tag = reflect.StructTag(raw)
+		}
+		names := make([]string, 0, len(fld.Names))
+		for _, n := range fld.Names {
+			names = append(names, n.Name)
+		}
+		if len(names) == 0 { // embedded
+			names = []string{typeName(fld.Type)}
+		}
+
+		nested, ok, err := s.resolve(ref, fld.Type)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			if ok {
+				if err := s.walk(nested, prefix+tag.Get("envPrefix"), out, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			sf := reflect.StructField{Name: name, Tag: tag}
+			*out = append(*out, Var{
+				Env:      config.EnvName(sf, prefix),
+				Type:     types.ExprString(fld.Type),
+				Default:  tag.Get("default"),
+				Desc:     tag.Get("desc"),
+				Required: tag.Get("required") == "true",
+				Secret:   config.IsSecret(sf),
+			})
+		}
+	}
+	return nil
+}
+
+// resolve reports whether expr names a struct config.Load would descend into.
+func (s *scanner) resolve(ref structRef, expr ast.Expr) (structRef, bool, error) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch e := expr.(type) {
+	case *ast.StructType:
+		return structRef{st: e, file: ref.file, pkg: ref.pkg}, true, nil
+	case *ast.Ident:
+		r, ok := ref.pkg.structs[e.Name]
+		return r, ok && !ref.pkg.text[e.Name], nil
+	case *ast.SelectorExpr:
+		id, ok := e.X.(*ast.Ident)
+		if !ok {
+			return structRef{}, false, nil
+		}
+		path := importPath(ref.file, id.Name)
+		if path == "" || isStdlib(path) {
+			return structRef{}, false, nil // time.Duration, url.URL, ... are leaves
+		}
+		dir, err := packageDir(ref.pkg.dir, path)
+		if err != nil {
+			return structRef{}, false, err
+		}
+		p, err := s.parse(dir)
+		if err != nil {
+			return structRef{}, false, err
+		}
+		r, ok := p.structs[e.Sel.Name]
+		return r, ok && !p.text[e.Sel.Name], nil
+	}
+	return structRef{}, false, nil
+}
+
+// varTypes maps variable names to the struct type they were declared with:
+// `cfg := AppConfig{}`, `cfg := &AppConfig{}`, `cfg := new(AppConfig)` or
+// `var cfg AppConfig`.
+func varTypes(f *ast.File) map[string]string {
+	m := map[string]string{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for i, l := range n.Lhs {
+				id, ok := l.(*ast.Ident)
+				if !ok || i >= len(n.Rhs) {
+					continue
+				}
+				if t := exprType(n.Rhs[i]); t != "" {
+					m[id.Name] = t
+				}
+			}
+		case *ast.ValueSpec:
+			t := ""
+			if n.Type != nil {
+				t = typeName(n.Type)
+			}
+			for i, id := range n.Names {
+				if t != "" {
+					m[id.Name] = t
+				} else if i < len(n.Values) {
+					if vt := exprType(n.Values[i]); vt != "" {
+						m[id.Name] = vt
+					}
+				}
+			}
+		}
+		return true
+	})
+	return m
+}
+
+func exprType(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.CompositeLit:
+		if e.Type != nil {
+			return typeName(e.Type)
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return exprType(e.X)
+		}
+	case *ast.CallExpr:
+		if id, ok := e.Fun.(*ast.Ident); ok && id.Name == "new" && len(e.Args) == 1 {
+			return typeName(e.Args[0])
+		}
+	}
+	return ""
+}
+
+func argType(arg ast.Expr, vars map[string]string) string {
+	if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		arg = u.X
+	}
+	if id, ok := arg.(*ast.Ident); ok {
+		return vars[id.Name]
+	}
+	return exprType(arg)
+}
+
+func typeName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return typeName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	}
+	return ""
+}
+
+func recvName(e ast.Expr) string {
+	if star, ok := e.(*ast.StarExpr); ok {
+		e = star.X
+	}
+	if id, ok := e.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+func isPkg(e ast.Expr, alias string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == alias
+}
+
+// importName returns the local name of path in f, or "" if not imported.
+func importName(f *ast.File, path string) string {
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path {
+			if imp.Name != nil {
+				return imp.Name.Name
+			}
+			return path[strings.LastIndex(path, "/")+1:]
+		}
+	}
+	return ""
+}
+
+// importPath returns the path imported under name in f.
+func importPath(f *ast.File, name string) string {
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		local := p[strings.LastIndex(p, "/")+1:]
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		if local == name {
+			return p
+		}
+	}
+	return ""
+}
+
+func isStdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// packageDir asks the go tool where an import path lives, relative to the
+// module containing fromDir.
+func packageDir(fromDir, path string) (string, error) {
+	cmd := exec.Command("go", "list", "-find", "-f", "{{.Dir}}", path)
+	cmd.Dir = fromDir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go list %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,201 @@
+package configdocs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writePkg writes a single-file package main into a new directory.
+func writePkg(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const appSrc = `package main
+
+import (
+	"time"
+
+	cfgpkg "github.com/ranakdinesh/spur/config"
+)
+
+type Level string
+
+func (l *Level) UnmarshalText(b []byte) error { *l = Level(b); return nil }
+
+type DB struct {
+	URL      string ` + "`" + `required:"true" desc:"Postgres DSN"` + "`" + `
+	MaxConns int    ` + "`" + `default:"10"` + "`" + `
+}
+
+type Common struct {
+	LogLevel Level ` + "`" + `default:"info"` + "`" + `
+}
+
+type AppConfig struct {
+	Common
+	Port     int           ` + "`" + `env:"PORT" default:"8080" desc:"listen port | http"` + "`" + `
+	Timeout  time.Duration ` + "`" + `default:"5s"` + "`" + `
+	APIToken string
+	Public   string ` + "`" + `secret:"false" env:"PUBLIC_KEY"` + "`" + `
+	DB       DB     ` + "`" + `envPrefix:"DB_"` + "`" + `
+	Cache    *struct {
+		Addr string
+	} ` + "`" + `envPrefix:"CACHE_"` + "`" + `
+	internal string
+}
+
+type Extra struct {
+	Region string
+	Port   int ` + "`" + `env:"PORT"` + "`" + `
+}
+
+type Unused struct {
+	Nope string
+}
+
+func main() {
+	cfg := &AppConfig{}
+	_ = cfgpkg.LoadWithOptions(cfg, cfgpkg.Options{})
+	var extra Extra
+	_, _ = cfgpkg.LoadOrigins(&extra, cfgpkg.Options{})
+}
+`
+
+func TestScan(t *testing.T) {
+	dir := writePkg(t, appSrc)
+	vars, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Var{
+		{Env: "LOG_LEVEL", Type: "Level", Default: "info"},
+		{Env: "PORT", Type: "int", Default: "8080", Desc: "listen port | http"},
+		{Env: "TIMEOUT", Type: "time.Duration", Default: "5s"},
+		{Env: "API_TOKEN", Type: "string", Secret: true},
+		{Env: "PUBLIC_KEY", Type: "string"},
+		{Env: "DB_URL", Type: "string", Desc: "Postgres DSN", Required: true},
+		{Env: "DB_MAX_CONNS", Type: "int", Default: "10"},
+		{Env: "CACHE_ADDR", Type: "string"},
+		{Env: "REGION", Type: "string"}, // Extra's PORT is already listed
+	}
+	if !slices.Equal(vars, want) {
+		t.Errorf("Scan() =\n%+v\nwant\n%+v", vars, want)
+	}
+}
+
+func TestScanRoots(t *testing.T) {
+	tests := []struct {
+		name string
+		call string
+		want []string
+	}{
+		{name: "Load", call: "var c C; _ = config.Load(&c)", want: []string{"A"}},
+		{name: "MustLoad", call: "config.MustLoad(new(C))", want: []string{"A"}},
+		{name: "LoadWithOptions", call: "c := C{}; _ = config.LoadWithOptions(&c, config.Options{})", want: []string{"A"}},
+		{name: "LoadOrigins", call: "_, _ = config.LoadOrigins(&C{}, config.Options{})", want: []string{"A"}},
+		{name: "Watch", call: "_, _ = config.Watch[C](nil, config.Options{})", want: []string{"A"}},
+		{name: "other function", call: "_ = config.EnvName", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writePkg(t, "package main\n\nimport \"github.com/ranakdinesh/spur/config\"\n\ntype C struct{ A string }\n\nfunc main() { "+tt.call+" }\n")
+			vars, err := Scan(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range vars {
+				got = append(got, v.Env)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Scan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	vars := []Var{
+		{Env: "PORT", Type: "int", Default: "8080", Desc: "listen port"},
+		{Env: "DB_URL", Type: "string", Required: true, Secret: true, Default: "postgres://dev"},
+	}
+	env := EnvExample(vars)
+	for _, want := range []string{"# listen port\nPORT=8080\n", "# (required) (secret)\nDB_URL=\n"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("EnvExample() missing %q:\n%s", want, env)
+		}
+	}
+	md := Markdown(vars)
+	if !strings.Contains(md, "| `PORT` | `int` | `8080` |  |  | listen port |") || !strings.Contains(md, "| `DB_URL` | `string` | `postgres://dev` | yes | yes |  |") {
+		t.Errorf("Markdown() =\n%s", md)
+	}
+	cm, sec := Manifests("orders", vars)
+	if !strings.Contains(cm, "name: orders-config") || !strings.Contains(cm, `PORT: "8080"`) || strings.Contains(cm, "DB_URL") {
+		t.Errorf("ConfigMap =\n%s", cm)
+	}
+	if !strings.Contains(sec, "name: orders-secret") || !strings.Contains(sec, `DB_URL: ""`) || strings.Contains(sec, "postgres://dev") {
+		t.Errorf("Secret =\n%s", sec)
+	}
+}
+
+func TestGenerateManifests(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // file already in the k8s dir
+		force    bool
+		wantErr  bool
+	}{
+		{name: "fresh"},
+		{name: "configmap exists", existing: "configmap.yaml", wantErr: true},
+		{name: "secret exists", existing: "secret.yaml", wantErr: true},
+		{name: "forced", existing: "secret.yaml", force: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writePkg(t, appSrc)
+			k8s := filepath.Join(t.TempDir(), "k8s")
+			const filled = "filled-in value\n"
+			if tt.existing != "" {
+				if err := os.MkdirAll(k8s, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(k8s, tt.existing), []byte(filled), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := Generate(Options{Dir: dir, Name: "orders", K8sDir: k8s, EnvFile: filepath.Join(dir, ".env.example"), Force: tt.force})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				// The existing manifest is left alone.
+				b, _ := os.ReadFile(filepath.Join(k8s, tt.existing))
+				if string(b) != filled {
+					t.Errorf("%s overwritten: %q", tt.existing, b)
+				}
+				return
+			}
+			for _, f := range []string{"configmap.yaml", "secret.yaml"} {
+				b, err := os.ReadFile(filepath.Join(k8s, f))
+				if err != nil || !strings.Contains(string(b), "orders-") {
+					t.Errorf("%s = %q, %v", f, b, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateNoConfig(t *testing.T) {
+	dir := writePkg(t, "package main\n\nfunc main() {}\n")
+	if err := Generate(Options{Dir: dir, Name: "x"}); err == nil {
+		t.Error("Generate() = nil error for a package without config.Load")
+	}
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ranakdinesh/spur/logger"
+)
+
+var testKey = []byte("test-key")
+
+// memSink keeps the chain in memory.
+type memSink struct{ events []Event }
+
+func (s *memSink) Append(_ context.Context, e Event, seal func(prev *Event) Event) error {
+	var prev *Event
+	if len(s.events) > 0 {
+		prev = &s.events[len(s.events)-1]
+	}
+	s.events = append(s.events, seal(prev))
+	return nil
+}
+
+func (s *memSink) Close(context.Context) error { return nil }
+
+func record(t *testing.T, a *Logger, n int) {
+	t.Helper()
+	for i := range n {
+		if err := a.Record(context.Background(), "update", fmt.Sprintf("order/%d", i), Success); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	sink := &memSink{}
+	a, err := New(Options{Key: testKey, Sink: sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(t, a, 5)
+	chain := sink.events
+
+	tests := []struct {
+		name    string
+		key     []byte
+		edit    func(ev []Event) []Event
+		wantSeq uint64 // 0: the chain verifies
+	}{
+		{name: "intact", edit: func(ev []Event) []Event { return ev }},
+		{name: "range without the head", edit: func(ev []Event) []Event { return ev[2:] }},
+		{name: "truncated tail", edit: func(ev []Event) []Event { return ev[:3] }},
+		{name: "empty", edit: func([]Event) []Event { return nil }},
+		{name: "wrong key", key: []byte("other"), edit: func(ev []Event) []Event { return ev }, wantSeq: 1},
+		{name: "altered field", edit: func(ev []Event) []Event { ev[2].Outcome = Denied; return ev }, wantSeq: 3},
+		{name: "altered and rehashed without the key", edit: func(ev []Event) []Event {
+			ev[2].Actor = "mallory"
+			ev[2].Hash = sum([]byte("guess"), ev[2])
+			return ev
+		}, wantSeq: 3},
+		{name: "removed record", edit: func(ev []Event) []Event { return slices.Delete(ev, 2, 3) }, wantSeq: 4},
+		{name: "reordered", edit: func(ev []Event) []Event { ev[1], ev[2] = ev[2], ev[1]; return ev }, wantSeq: 3},
+		{name: "duplicated", edit: func(ev []Event) []Event { return slices.Insert(ev, 2, ev[1]) }, wantSeq: 2},
+		{name: "renumbered after removal", edit: func(ev []Event) []Event {
+			ev = slices.Delete(ev, 2, 3)
+			for i := 2; i < len(ev); i++ {
+				ev[i].Seq--
+			}
+			return ev
+		}, wantSeq: 3},
+		{name: "head given a predecessor", edit: func(ev []Event) []Event { ev[0].Prev = ev[1].Hash; return ev }, wantSeq: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			if key == nil {
+				key = testKey
+			}
+			err := VerifyChain(key, tt.edit(slices.Clone(chain)))
+			if tt.wantSeq == 0 {
+				if err != nil {
+					t.Errorf("VerifyChain() = %v", err)
+				}
+				return
+			}
+			var ce *ChainError
+			if !errors.As(err, &ce) {
+				t.Fatalf("VerifyChain() = %v, want a *ChainError", err)
+			}
+			if ce.Seq != tt.wantSeq {
+				t.Errorf("broken at seq %d (%s), want %d", ce.Seq, ce.Reason, tt.wantSeq)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "no key", opts: Options{Sink: &memSink{}}},
+		{name: "no sink", opts: Options{Key: testKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); err == nil {
+				t.Error("New() = nil error")
+			}
+		})
+	}
+}
+
+func TestFileSinkChain(t *testing.T) {
+	tests := []struct {
+		name string
+		file string // default audit.log
+		cfg  logger.FileSinkConfig
+	}{
+		{name: "single file"},
+		// Each record is a few hundred bytes: every write rotates, faster
+		// than the millisecond in the rotated names.
+		{name: "rotated", cfg: logger.FileSinkConfig{MaxSize: 100}},
+		{name: "rotated compressed", cfg: logger.FileSinkConfig{MaxSize: 100, Compress: true}},
+		{name: "no extension compressed", file: "audit", cfg: logger.FileSinkConfig{MaxSize: 100, Compress: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Path = filepath.Join(t.TempDir(), cmp.Or(tt.file, "audit.log"))
+
+			// Two runs: the second resumes the chain from the files.
+			for run := range 2 {
+				sink, err := NewFileSink(cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				a, err := New(Options{Key: testKey, Sink: sink})
+				if err != nil {
+					t.Fatal(err)
+				}
+				record(t, a, 4)
+				if err := a.Close(context.Background()); err != nil {
+					t.Fatalf("run %d: %v", run, err)
+				}
+			}
+
+			events, err := ReadFiles(cfg.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 8 || events[0].Seq != 1 || events[7].Seq != 8 {
+				t.Fatalf("read %d events: %+v", len(events), events)
+			}
+			if err := VerifyChain(testKey, events); err != nil {
+				t.Error(err)
+			}
+			if cfg.MaxSize > 0 && len(logger.RotatedFiles(cfg.Path)) == 0 {
+				t.Error("no rotated files")
+			}
+		})
+	}
+}
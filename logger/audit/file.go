@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ranakdinesh/spur/logger"
+)
+
+// FileSink writes records as JSON lines through a Loggerx over a
+// logger.FileSink, so rotation, compression and retention work as for the app
+// log; give it a path of its own. On open the chain resumes from the last
+// record in the newest file.
+type FileSink struct {
+	path string
+	fs   *logger.FileSink
+	log  *logger.Loggerx
+
+	mu   sync.Mutex
+	last *Event
+}
+
+func NewFileSink(cfg logger.FileSinkConfig) (*FileSink, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("audit: file sink: empty path")
+	}
+	last, err := lastRecord(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := logger.NewFileSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Records are signed as written: never redact or sample them.
+	log := logger.NewWithWriter(fs, logger.Options{Level: "info", DisableRedaction: true})
+	return &FileSink{path: cfg.Path, fs: fs, log: log, last: last}, nil
+}
+
+func (s *FileSink) Append(_ context.Context, e Event, seal func(prev *Event) Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e = seal(s.last)
+	failed := s.fs.Stats().Failed
+	// No request context: its fields could shadow the schema.
+	fields(s.log.Info(context.Background()), e).Msg("audit")
+	if s.fs.Stats().Failed != failed {
+		return fmt.Errorf("audit: file sink: write to %s failed", s.path)
+	}
+	s.last = &e
+	return nil
+}
+
+// Close syncs and closes the file.
+func (s *FileSink) Close(ctx context.Context) error { return s.log.Close(ctx) }
+
+// ReadFiles returns the records at path and in its rotated (and gzipped)
+// predecessors, oldest first, ready for VerifyChain.
+func ReadFiles(path string) ([]Event, error) {
+	var out []Event
+	for _, f := range streamFiles(path) {
+		events, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, events...)
+	}
+	return out, nil
+}
+
+// lastRecord finds the newest record of the stream, nil if there is none.
+func lastRecord(path string) (*Event, error) {
+	files := streamFiles(path)
+	for i := len(files) - 1; i >= 0; i-- {
+		events, err := readFile(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(events) > 0 {
+			return &events[len(events)-1], nil
+		}
+	}
+	return nil, nil
+}
+
+// streamFiles lists the files rotated out by logger.FileSink oldest first,
+// then path itself if it exists.
+func streamFiles(path string) []string {
+	files := logger.RotatedFiles(path)
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// readFile parses the records of one file; other lines are skipped.
+func readFile(name string) ([]Event, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("audit: %s: %w", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var out []Event
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e Event
+		if json.Unmarshal(sc.Bytes(), &e) != nil || e.Hash == "" {
+			continue
+		}
+		out = append(out, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("audit: %s: %w", name, err)
+	}
+	return out, nil
+}
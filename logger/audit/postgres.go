@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Schema is the table PostgresSink writes (%s is the table name). Grant the
+// application role INSERT and SELECT only.
+const Schema = `CREATE TABLE IF NOT EXISTS %s (
+	seq        bigint PRIMARY KEY,
+	at         timestamptz NOT NULL,
+	actor      text NOT NULL,
+	tenant     text NOT NULL,
+	action     text NOT NULL,
+	resource   text NOT NULL,
+	outcome    text NOT NULL,
+	request_id text NOT NULL,
+	prev       text NOT NULL,
+	hash       text NOT NULL
+)`
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// PostgresSink inserts records into a table. Appends take a transaction-level
+// advisory lock, so replicas sharing the table extend one chain.
+type PostgresSink struct {
+	pool  *pgxpool.Pool
+	table string
+}
+
+// NewPostgresSink creates table (default "audit_log") if it doesn't exist.
+func NewPostgresSink(ctx context.Context, pool *pgxpool.Pool, table string) (*PostgresSink, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("audit: nil pool")
+	}
+	if table == "" {
+		table = "audit_log"
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("audit: invalid table name %q", table)
+	}
+	if _, err := pool.Exec(ctx, fmt.Sprintf(Schema, table)); err != nil {
+		return nil, fmt.Errorf("audit: create table: %w", err)
+	}
+	return &PostgresSink{pool: pool, table: table}, nil
+}
+
+func (s *PostgresSink) Append(ctx context.Context, e Event, seal func(prev *Event) Event) error {
+	err := pgx.BeginTxFunc(ctx, s.pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "audit:"+s.table); err != nil {
+			return err
+		}
+		var prev *Event
+		rows, err := tx.Query(ctx, `SELECT `+columns+` FROM `+s.table+` ORDER BY seq DESC LIMIT 1`)
+		if err != nil {
+			return err
+		}
+		events, err := scanEvents(rows)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			prev = &events[0]
+		}
+		e = seal(prev)
+		_, err = tx.Exec(ctx, `INSERT INTO `+s.table+` (`+columns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
+			int64(e.Seq), e.Time, e.Actor, e.Tenant, e.Action, e.Resource, string(e.Outcome), e.RequestID, e.Prev, e.Hash)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("audit: postgres: %w", err)
+	}
+	return nil
+}
+
+// Events returns the records with seq >= from in order, ready for VerifyChain.
+func (s *PostgresSink) Events(ctx context.Context, from uint64) ([]Event, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+columns+` FROM `+s.table+` WHERE seq >= $1 ORDER BY seq`, int64(from))
+	if err != nil {
+		return nil, fmt.Errorf("audit: postgres: %w", err)
+	}
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("audit: postgres: %w", err)
+	}
+	return events, nil
+}
+
+// Close is a no-op; the pool belongs to the caller.
+func (s *PostgresSink) Close(context.Context) error { return nil }
+
+const columns = `seq, at, actor, tenant, action, resource, outcome, request_id, prev, hash`
+
+func scanEvents(rows pgx.Rows) ([]Event, error) {
+	defer rows.Close()
+	var out []Event
+	for rows.Next() {
+		var e Event
+		var seq int64
+		var outcome string
+		if err := rows.Scan(&seq, &e.Time, &e.Actor, &e.Tenant, &e.Action, &e.Resource, &outcome, &e.RequestID, &e.Prev, &e.Hash); err != nil {
+			return nil, err
+		}
+		e.Seq, e.Outcome, e.Time = uint64(seq), Outcome(outcome), e.Time.UTC()
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
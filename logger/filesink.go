@@ -0,0 +1,344 @@
+package logger
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// FileSinkConfig configures a log file with rotation and retention.
+type FileSinkConfig struct {
+	Path string // "" disables the file sink
+
+	// Rotate when the file would exceed MaxSize bytes (default 100 MiB) or
+	// has been open for RotateEvery (0 = size only). Rotated files are
+	// renamed to name-<time>.ext (name-<time>.<n>.ext on a clash) and gzipped
+	// if Compress is set.
+	MaxSize     int64
+	RotateEvery time.Duration
+	Compress    bool
+
+	// Retention of rotated files; 0 keeps them all.
+	MaxAge     time.Duration
+	MaxBackups int
+
+	Perm os.FileMode // default 0640
+}
+
+// FileSink appends log lines to a file and rotates it. It also reopens the
+// file on SIGHUP, so an external logrotate can move it away safely.
+// Write errors are counted rather than returned so they never break the tee.
+type FileSink struct {
+	cfg FileSinkConfig
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	bg     sync.WaitGroup // compression and cleanup after rotation
+	bgMu   sync.Mutex     // one compression/cleanup at a time
+	stop   chan struct{}
+	closed bool
+
+	sent, failed atomic.Uint64
+}
+
+const rotateTimeFormat = "20060102T150405.000"
+
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 100 << 20
+	}
+	if cfg.Perm == 0 {
+		cfg.Perm = 0o640
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("logger: file sink: %w", err)
+	}
+	s := &FileSink{cfg: cfg, stop: make(chan struct{})}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-hup:
+				_ = s.Reopen()
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (s *FileSink) String() string { return "file" }
+
+func (s *FileSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.failed.Add(1)
+		return len(p), nil
+	}
+	if s.size > 0 && (s.size+int64(len(p)) > s.cfg.MaxSize ||
+		(s.cfg.RotateEvery > 0 && time.Since(s.opened) >= s.cfg.RotateEvery)) {
+		if err := s.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	if s.f == nil {
+		s.failed.Add(1)
+		return len(p), nil
+	}
+	n, err := s.f.Write(p)
+	s.size += int64(n)
+	if err != nil {
+		s.failed.Add(1)
+	} else {
+		s.sent.Add(1)
+	}
+	return len(p), nil
+}
+
+// Reopen closes and reopens Path, e.g. after logrotate moved it away.
+func (s *FileSink) Reopen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	return s.open()
+}
+
+// Flush syncs the file to disk.
+func (s *FileSink) Flush(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Sync()
+}
+
+// Close syncs and closes the file and waits for pending compression.
+func (s *FileSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	var err error
+	if s.f != nil {
+		err = s.f.Sync()
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+		s.f = nil
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() { s.bg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+// Stats reports written (Sent) and failed lines.
+func (s *FileSink) Stats() SinkStats {
+	return SinkStats{Sent: s.sent.Load(), Failed: s.failed.Load()}
+}
+
+// open opens Path for appending; callers hold mu.
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, s.cfg.Perm)
+	if err != nil {
+		return fmt.Errorf("logger: file sink: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("logger: file sink: %w", err)
+	}
+	s.f, s.size, s.opened = f, st.Size(), time.Now()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one; callers hold mu.
+func (s *FileSink) rotate() error {
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	rotated := rotatedName(s.cfg.Path, time.Now())
+	if err := os.Rename(s.cfg.Path, rotated); err != nil && !os.IsNotExist(err) {
+		_ = s.open() // keep writing to the old file rather than losing lines
+		return fmt.Errorf("logger: file sink: rotate: %w", err)
+	}
+	s.bg.Add(1)
+	go func() {
+		defer s.bg.Done()
+		s.bgMu.Lock()
+		defer s.bgMu.Unlock()
+		if s.cfg.Compress {
+			// An earlier prune may already have removed it as an old backup.
+			if err := gzipFile(rotated); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "logger: file sink: compress: %v\n", err)
+			}
+		}
+		s.prune()
+	}()
+	return s.open()
+}
+
+// prune applies MaxBackups and MaxAge to rotated files.
+func (s *FileSink) prune() {
+	if s.cfg.MaxBackups <= 0 && s.cfg.MaxAge <= 0 {
+		return
+	}
+	files := RotatedFiles(s.cfg.Path)
+	for i, f := range files {
+		tooMany := s.cfg.MaxBackups > 0 && len(files)-i > s.cfg.MaxBackups
+		tooOld := false
+		if st, err := os.Stat(f); err == nil && s.cfg.MaxAge > 0 {
+			tooOld = time.Since(st.ModTime()) > s.cfg.MaxAge
+		}
+		if tooMany || tooOld {
+			_ = os.Remove(f)
+		}
+	}
+}
+
+// rotatedName names the backup of path rotated at now: path-<time>.ext, or
+// path-<time>.<n>.ext if a backup (plain or gzipped) from the same
+// millisecond exists.
+func rotatedName(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext) + "-" + now.Format(rotateTimeFormat)
+	name := stem + ext
+	for n := 1; exists(name) || exists(name+".gz"); n++ {
+		name = stem + "." + strconv.Itoa(n) + ext
+	}
+	return name
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// RotatedFiles lists the backups a FileSink at path has rotated out, plain
+// or gzipped, oldest first. Only names rotatedName produces match, so
+// siblings such as app-access.log are left out.
+func RotatedFiles(path string) []string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, _ := os.ReadDir(firstNonEmpty(dir, "."))
+
+	type backup struct {
+		path  string
+		stamp string
+		n     int
+	}
+	var backups []backup
+	for _, e := range entries {
+		mid, ok := strings.CutPrefix(strings.TrimSuffix(e.Name(), ".gz"), prefix)
+		if !ok {
+			continue
+		}
+		if mid, ok = strings.CutSuffix(mid, ext); !ok {
+			continue
+		}
+		if stamp, n, ok := parseRotated(mid); ok {
+			backups = append(backups, backup{filepath.Join(dir, e.Name()), stamp, n})
+		}
+	}
+	// Order by rotation time, then sequence number.
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp < backups[j].stamp
+		}
+		return backups[i].n < backups[j].n
+	})
+	files := make([]string, len(backups))
+	for i, b := range backups {
+		files[i] = b.path
+	}
+	return files
+}
+
+// parseRotated splits the part of a backup name between the prefix and the
+// extension: a rotateTimeFormat stamp, optionally followed by ".N".
+func parseRotated(mid string) (stamp string, n int, ok bool) {
+	if len(mid) < len(rotateTimeFormat) {
+		return "", 0, false
+	}
+	stamp, seq := mid[:len(rotateTimeFormat)], mid[len(rotateTimeFormat):]
+	if _, err := time.Parse(rotateTimeFormat, stamp); err != nil {
+		return "", 0, false
+	}
+	if seq == "" {
+		return stamp, 0, true
+	}
+	digits, ok := strings.CutPrefix(seq, ".")
+	if !ok {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+	return stamp, n, true
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,241 @@
+package logger
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readLines returns the lines of a log file, gunzipping .gz files.
+func readLines(t *testing.T, name string) []string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	var out []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		out = append(out, sc.Text())
+	}
+	return out
+}
+
+// allLines reads the backups oldest first, then the live file.
+func allLines(t *testing.T, path string) (lines []string, backups int) {
+	t.Helper()
+	files := RotatedFiles(path)
+	for _, f := range files {
+		lines = append(lines, readLines(t, f)...)
+	}
+	return append(lines, readLines(t, path)...), len(files)
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string // default app.log
+		cfg         FileSinkConfig
+		lines       int
+		wantBackups int // -1: don't check
+		wantAll     bool
+	}{
+		// 10-byte lines, 2 per file: rotations come faster than the
+		// millisecond in the rotated names.
+		{name: "size", cfg: FileSinkConfig{MaxSize: 25}, lines: 20, wantBackups: 9, wantAll: true},
+		{name: "size compressed", cfg: FileSinkConfig{MaxSize: 25, Compress: true}, lines: 20, wantBackups: 9, wantAll: true},
+		{name: "max backups", cfg: FileSinkConfig{MaxSize: 25, MaxBackups: 3}, lines: 20, wantBackups: 3},
+		{name: "max backups compressed", cfg: FileSinkConfig{MaxSize: 25, MaxBackups: 2, Compress: true}, lines: 20, wantBackups: 2},
+		{name: "no rotation", cfg: FileSinkConfig{MaxSize: 1 << 20}, lines: 20, wantBackups: 0, wantAll: true},
+		{name: "no extension compressed", file: "app", cfg: FileSinkConfig{MaxSize: 25, Compress: true}, lines: 20, wantBackups: 9, wantAll: true},
+		{name: "no extension max backups", file: "app", cfg: FileSinkConfig{MaxSize: 25, MaxBackups: 3, Compress: true}, lines: 20, wantBackups: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Path = filepath.Join(t.TempDir(), cmp.Or(tt.file, "app.log"))
+			s, err := NewFileSink(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for i := range tt.lines {
+				line := fmt.Sprintf("line %04d", i)
+				want = append(want, line)
+				if _, err := s.Write([]byte(line + "\n")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			got, backups := allLines(t, cfg.Path)
+			if backups != tt.wantBackups {
+				t.Errorf("%d backups, want %d", backups, tt.wantBackups)
+			}
+			if tt.wantAll {
+				if !slices.Equal(got, want) {
+					t.Errorf("lines = %v, want %v", got, want)
+				}
+			} else if !slices.Equal(got, want[len(want)-len(got):]) {
+				t.Errorf("lines = %v, want the newest %d in order", got, len(got))
+			}
+			if st := s.Stats(); st.Sent != uint64(tt.lines) || st.Failed != 0 {
+				t.Errorf("Stats() = %+v", st)
+			}
+		})
+	}
+}
+
+func TestFileSinkRotateEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	s, err := NewFileSink(FileSinkConfig{Path: path, RotateEvery: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+	_, _ = s.Write([]byte("a\n"))
+	_, _ = s.Write([]byte("b\n"))
+	time.Sleep(30 * time.Millisecond)
+	_, _ = s.Write([]byte("c\n"))
+
+	files := RotatedFiles(path)
+	if len(files) != 1 {
+		t.Fatalf("backups = %v, want 1", files)
+	}
+	if got := readLines(t, files[0]); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("backup = %v", got)
+	}
+	if got := readLines(t, path); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("live file = %v", got)
+	}
+}
+
+func TestFileSinkReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	s, err := NewFileSink(FileSinkConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+	_, _ = s.Write([]byte("before\n"))
+	// What logrotate does before sending SIGHUP.
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Write([]byte("after\n"))
+	if got := readLines(t, path); !slices.Equal(got, []string{"after"}) {
+		t.Errorf("live file = %v", got)
+	}
+	if got := readLines(t, filepath.Join(dir, "app.log.1")); !slices.Equal(got, []string{"before"}) {
+		t.Errorf("moved file = %v", got)
+	}
+}
+
+func TestRotatedName(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 123e6, time.Local)
+	stamp := "20240501T123000.123"
+	tests := []struct {
+		name     string
+		existing []string
+		want     string
+	}{
+		{name: "free", want: "app-" + stamp + ".log"},
+		{name: "taken", existing: []string{"app-" + stamp + ".log"}, want: "app-" + stamp + ".1.log"},
+		{name: "taken gzipped", existing: []string{"app-" + stamp + ".log.gz"}, want: "app-" + stamp + ".1.log"},
+		{
+			name:     "several taken",
+			existing: []string{"app-" + stamp + ".log.gz", "app-" + stamp + ".1.log.gz", "app-" + stamp + ".2.log"},
+			want:     "app-" + stamp + ".3.log",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := rotatedName(filepath.Join(dir, "app.log"), now); got != filepath.Join(dir, tt.want) {
+				t.Errorf("rotatedName() = %s, want %s", filepath.Base(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatedFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		want  []string
+		other []string // files that must not be listed
+	}{
+		{
+			name: "ordered",
+			path: "app.log",
+			want: []string{
+				"app-20240501T120000.000.log.gz",
+				"app-20240501T120000.000.1.log.gz",
+				"app-20240501T120000.000.2.log",
+				"app-20240501T120000.000.10.log",
+				"app-20240501T120000.001.log.gz",
+				"app-20240502T000000.000.log",
+			},
+			other: []string{
+				"app.log", "other-20240501T120000.000.log", "app-access.log",
+				"app-20240501T120000.000.x.log", "app-20240501T120000.000.0.log", "app-2024.log",
+			},
+		},
+		{
+			name: "no extension",
+			path: "app",
+			want: []string{
+				"app-20240501T120000.000.gz",
+				"app-20240501T120000.000.1",
+				"app-20240501T120000.001",
+			},
+			other: []string{"app", "app-trail", "app-trail.gz", "app-access.log", "app-20240501T120000.001.log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range append(slices.Clone(tt.want), tt.other...) {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []string
+			for _, f := range RotatedFiles(filepath.Join(dir, tt.path)) {
+				got = append(got, filepath.Base(f))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("RotatedFiles() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,38 +2,141 @@ package logger
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type HTTPSinkConfig struct {
 	URL     string
 	APIKey  string
-	Timeout time.Duration
-	Buffer  int // number of log lines buffered (dropping when full)
+	Headers map[string]string // extra request headers
+	Timeout time.Duration     // per request
+	Buffer  int               // number of log lines buffered (dropping when full)
+
+	// Batching: a batch is POSTed as NDJSON once it holds BatchSize lines or
+	// BatchBytes bytes, or FlushInterval after its first line (defaults 500,
+	// 1 MiB, 1s).
+	BatchSize     int
+	BatchBytes    int
+	FlushInterval time.Duration
+
+	// Gzip compresses request bodies (Content-Encoding: gzip).
+	Gzip bool
+
+	// Failed batches (network errors, 429, 5xx) are retried up to MaxRetries
+	// times with exponential backoff starting at RetryBackoff and capped at
+	// MaxBackoff (defaults 3, 200ms, 5s). Other 4xx responses are not retried.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Spool keeps undeliverable batches on disk instead of dropping them and
+	// retries them every MaxBackoff; see SpoolConfig.
+	Spool SpoolConfig
+}
+
+// Sink is a log writer that buffers internally and must be flushed before the
+// process exits. Loggerx.Flush and Loggerx.Close fan out to its sinks.
+type Sink interface {
+	io.Writer
+	Flush(ctx context.Context) error
+	Close(ctx context.Context) error
 }
 
-// HTTPSink implements io.Writer. It POSTs each JSON log line to the URL.
-// It is non-blocking: if buffer is full, it drops lines (never stalls the app).
+// SinkStats counts log lines by outcome since the sink was created.
+type SinkStats struct {
+	Sent    uint64 // accepted by the endpoint
+	Dropped uint64 // never sent: buffer full, sink closed or evicted from the spool
+	Failed  uint64 // sent but rejected, or retries exhausted
+	Spooled uint64 // written to the disk spool (and later Sent or Dropped)
+}
+
+// HTTPSink implements io.Writer. It batches JSON log lines and POSTs them to
+// the URL as NDJSON. Writes never block: if the buffer is full, the line is
+// dropped and counted (see Stats).
 type HTTPSink struct {
 	cfg    HTTPSinkConfig
 	client *http.Client
 	ch     chan []byte
+	ctl    chan sinkReq
+	done   chan struct{}
+
+	spool *spool // nil unless cfg.Spool.Dir is set
+
+	// Wire format; nil means NDJSON (see NewOTLPSink for another).
+	name        string
+	format      func(batch [][]byte) ([]byte, error)
+	contentType string
+
+	closeOnce sync.Once
+	closed    atomic.Bool
+
+	sent, dropped, failed, spooled atomic.Uint64
 }
 
+// sinkReq asks the loop to send everything buffered so far (and, for close,
+// to exit afterwards). The result is reported on reply.
+type sinkReq struct {
+	ctx   context.Context
+	close bool
+	reply chan error
+}
+
+var errSinkClosed = errors.New("logger: sink closed")
+
 func NewHTTPSink(cfg HTTPSinkConfig) *HTTPSink {
+	s := newHTTPSink(cfg)
+	go s.loop()
+	return s
+}
+
+// newHTTPSink applies defaults and opens the spool; the caller starts loop.
+func newHTTPSink(cfg HTTPSinkConfig) *HTTPSink {
+	cfg.Timeout = firstNonZero(cfg.Timeout, time.Second)
+	cfg.Buffer = firstNonZeroInt(cfg.Buffer, 1024)
+	cfg.BatchSize = firstNonZeroInt(cfg.BatchSize, 500)
+	cfg.BatchBytes = firstNonZeroInt(cfg.BatchBytes, 1<<20)
+	cfg.FlushInterval = firstNonZero(cfg.FlushInterval, time.Second)
+	cfg.MaxRetries = firstNonZeroInt(cfg.MaxRetries, 3)
+	cfg.RetryBackoff = firstNonZero(cfg.RetryBackoff, 200*time.Millisecond)
+	cfg.MaxBackoff = firstNonZero(cfg.MaxBackoff, 5*time.Second)
+
 	s := &HTTPSink{
 		cfg: cfg,
 		client: &http.Client{
 			Timeout: cfg.Timeout,
 		},
-		ch: make(chan []byte, cfg.Buffer),
+		ch:   make(chan []byte, cfg.Buffer),
+		ctl:  make(chan sinkReq),
+		done: make(chan struct{}),
+	}
+	if cfg.Spool.Dir != "" {
+		sp, err := openSpool(cfg.Spool)
+		if err != nil {
+			// The logger can't log about itself yet; stderr still reaches kubectl logs.
+			fmt.Fprintf(os.Stderr, "%v; continuing without spool\n", err)
+		}
+		s.spool = sp
 	}
-	go s.loop()
 	return s
 }
 
+func (s *HTTPSink) String() string { return firstNonEmpty(s.name, "http") }
+
 func (s *HTTPSink) Write(p []byte) (int, error) {
+	if s.closed.Load() {
+		s.dropped.Add(1)
+		return len(p), nil
+	}
 	// copy to avoid reuse of underlying slice by zerolog
 	cp := make([]byte, len(p))
 	copy(cp, p)
@@ -41,22 +144,304 @@ func (s *HTTPSink) Write(p []byte) (int, error) {
 	select {
 	case s.ch <- cp:
 	default:
-		// buffer full: drop silently (best effort)
+		s.dropped.Add(1) // buffer full: best effort, never stall the app
 	}
 	return len(p), nil
 }
 
+// Flush sends everything written so far and waits until it is delivered or
+// given up on, or ctx is done.
+func (s *HTTPSink) Flush(ctx context.Context) error {
+	return s.request(ctx, false)
+}
+
+// Close flushes and stops the sink. Later writes are dropped. Calling Close
+// more than once is safe.
+func (s *HTTPSink) Close(ctx context.Context) error {
+	err := errSinkClosed
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		err = s.request(ctx, true)
+	})
+	if errors.Is(err, errSinkClosed) {
+		return nil
+	}
+	return err
+}
+
+// Stats reports how many lines were sent, dropped and failed so far.
+func (s *HTTPSink) Stats() SinkStats {
+	return SinkStats{Sent: s.sent.Load(), Dropped: s.dropped.Load(), Failed: s.failed.Load(), Spooled: s.spooled.Load()}
+}
+
+func (s *HTTPSink) request(ctx context.Context, close bool) error {
+	req := sinkReq{ctx: ctx, close: close, reply: make(chan error, 1)}
+	select {
+	case s.ctl <- req:
+	case <-s.done:
+		return errSinkClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *HTTPSink) loop() {
-	for line := range s.ch {
-		req, _ := http.NewRequest("POST", s.cfg.URL, bytes.NewReader(line))
-		req.Header.Set("Content-Type", "application/json")
-		if s.cfg.APIKey != "" {
-			req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	defer close(s.done)
+
+	t := time.NewTimer(s.cfg.FlushInterval)
+	t.Stop()
+	var replay <-chan time.Time
+	if s.spool != nil {
+		rt := time.NewTicker(s.cfg.MaxBackoff)
+		defer rt.Stop()
+		defer s.spool.close()
+		replay = rt.C
+	}
+	var (
+		batch   [][]byte
+		size    int
+		pending bool // timer armed for the current batch
+	)
+	send := func(ctx context.Context) error {
+		if pending {
+			t.Stop()
+			pending = false
 		}
-		// best effort: one retry after a short backoff
-		if _, err := s.client.Do(req); err != nil {
-			time.Sleep(100 * time.Millisecond)
-			s.client.Do(req) // ignore error on retry
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.send(ctx, batch)
+		batch, size = nil, 0
+		return err
+	}
+	add := func(line []byte) {
+		batch = append(batch, line)
+		size += len(line)
+		if len(batch) >= s.cfg.BatchSize || size >= s.cfg.BatchBytes {
+			_ = send(context.Background())
+		} else if !pending {
+			t.Reset(s.cfg.FlushInterval)
+			pending = true
+		}
+	}
+
+	for {
+		select {
+		case line := <-s.ch:
+			add(line)
+		case <-t.C:
+			pending = false
+			_ = send(context.Background())
+		case <-replay:
+			if !s.spool.empty() {
+				_ = s.replay(context.Background())
+			}
+		case req := <-s.ctl:
+			// Take everything written before the request, then send it all.
+			var err error
+		drain:
+			for {
+				select {
+				case line := <-s.ch:
+					batch = append(batch, line)
+					size += len(line)
+					if len(batch) >= s.cfg.BatchSize || size >= s.cfg.BatchBytes {
+						err = errors.Join(err, send(req.ctx))
+					}
+				default:
+					break drain
+				}
+			}
+			err = errors.Join(err, send(req.ctx))
+			if s.spool != nil && !req.close && !s.spool.empty() {
+				err = errors.Join(err, s.replay(req.ctx))
+			}
+			req.reply <- err
+			if req.close {
+				s.dropped.Add(uint64(len(s.ch))) // raced with Close
+				return
+			}
 		}
 	}
 }
+
+// send delivers one batch. With a spool, batches that can't be delivered (and
+// every batch while older ones are still spooled) are written to disk instead.
+func (s *HTTPSink) send(ctx context.Context, batch [][]byte) error {
+	raw := ndjson(batch)
+	n := uint64(len(batch))
+	if s.spool != nil && !s.spool.empty() {
+		return s.toSpool(raw, n) // keep order behind older spooled lines
+	}
+	retry, err := s.postRetry(ctx, batch)
+	switch {
+	case err == nil:
+		s.sent.Add(n)
+	case s.spool != nil && (retry || ctx.Err() != nil):
+		return s.toSpool(raw, n)
+	default:
+		s.failed.Add(n)
+	}
+	return err
+}
+
+// postRetry POSTs a batch, retrying with backoff. The body is encoded once
+// and re-read from the start on every attempt.
+func (s *HTTPSink) postRetry(ctx context.Context, batch [][]byte) (retry bool, err error) {
+	body, err := s.encode(batch)
+	if err != nil {
+		return false, err
+	}
+	backoff := s.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, body)
+		if err == nil || !retry || attempt >= s.cfg.MaxRetries {
+			return retry, err
+		}
+		// Full jitter keeps many pods from retrying in lockstep.
+		wait := time.Duration(rand.Int64N(int64(backoff)) + 1)
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, s.cfg.MaxBackoff)
+	}
+}
+
+func (s *HTTPSink) toSpool(raw []byte, n uint64) error {
+	evicted, err := s.spool.append(raw)
+	if err != nil {
+		s.failed.Add(n)
+		return err
+	}
+	s.spooled.Add(n)
+	s.dropped.Add(uint64(evicted))
+	return nil
+}
+
+// replay sends spooled lines oldest first, in batches, until the spool is
+// empty or the endpoint fails again.
+func (s *HTTPSink) replay(ctx context.Context) error {
+	s.dropped.Add(uint64(s.spool.evict(time.Now())))
+	for !s.spool.empty() {
+		data, err := s.spool.head()
+		if err != nil {
+			s.spool.drop() // unreadable segment; nothing left to salvage
+			continue
+		}
+		if len(data) == 0 {
+			s.spool.ack(0, true)
+			continue
+		}
+		for len(data) > 0 {
+			chunk, n := s.cut(data)
+			if n == 0 { // torn last line from a crash mid-write
+				s.spool.ack(len(data), true)
+				break
+			}
+			body, err := s.encode(bytes.SplitAfter(chunk[:len(chunk)-1], []byte{'\n'}))
+			if err != nil {
+				return err
+			}
+			if retry, err := s.post(ctx, body); err != nil && retry {
+				return err
+			} else if err != nil {
+				s.failed.Add(uint64(n)) // rejected for good; don't block the rest
+			} else {
+				s.sent.Add(uint64(n))
+			}
+			data = data[len(chunk):]
+			s.spool.ack(len(chunk), len(data) == 0)
+		}
+	}
+	return nil
+}
+
+// cut returns the leading complete lines of data that fit in one batch.
+func (s *HTTPSink) cut(data []byte) (chunk []byte, lines int) {
+	end := 0
+	for lines < s.cfg.BatchSize {
+		i := bytes.IndexByte(data[end:], '\n')
+		if i < 0 || (lines > 0 && end+i+1 > s.cfg.BatchBytes) {
+			break
+		}
+		end += i + 1
+		lines++
+	}
+	return data[:end], lines
+}
+
+// ndjson joins the lines, one JSON document per line.
+func ndjson(batch [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range batch {
+		buf.Write(line)
+		// zerolog terminates lines with '\n' already; guard custom writers.
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// encode renders a batch in the sink's wire format, gzipped if configured.
+func (s *HTTPSink) encode(batch [][]byte) ([]byte, error) {
+	raw := ndjson(batch)
+	if s.format != nil {
+		var err error
+		if raw, err = s.format(batch); err != nil {
+			return nil, err
+		}
+	}
+	if !s.cfg.Gzip {
+		return raw, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends one request and reports whether a failure is worth retrying.
+func (s *HTTPSink) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", firstNonEmpty(s.contentType, "application/x-ndjson"))
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // allow connection reuse
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("logger: http sink: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("logger: http sink: %s", resp.Status)
+	}
+}
@@ -0,0 +1,270 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// sinkServer records every request and answers with the next status from
+// statuses (200 once they run out).
+type sinkServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	reqs     []sinkRequest
+}
+
+type sinkRequest struct {
+	header http.Header
+	lines  []string
+}
+
+func newSinkServer(t *testing.T, statuses ...int) *sinkServer {
+	ss := &sinkServer{statuses: statuses}
+	ss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		req := sinkRequest{header: r.Header.Clone()}
+		sc := bufio.NewScanner(body)
+		for sc.Scan() {
+			req.lines = append(req.lines, sc.Text())
+		}
+
+		ss.mu.Lock()
+		status := http.StatusOK
+		if len(ss.statuses) > 0 {
+			status, ss.statuses = ss.statuses[0], ss.statuses[1:]
+		}
+		ss.reqs = append(ss.reqs, req)
+		ss.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(ss.Close)
+	return ss
+}
+
+func (ss *sinkServer) requests() []sinkRequest {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return slices.Clone(ss.reqs)
+}
+
+// batchSizes returns the number of lines in each request.
+func (ss *sinkServer) batchSizes() []int {
+	var n []int
+	for _, r := range ss.requests() {
+		n = append(n, len(r.lines))
+	}
+	return n
+}
+
+func TestHTTPSinkBatching(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   HTTPSinkConfig
+		lines int
+		flush bool // call Flush; otherwise wait for the interval
+		want  []int
+	}{
+		{name: "by size", cfg: HTTPSinkConfig{BatchSize: 3, FlushInterval: time.Hour}, lines: 7, flush: true, want: []int{3, 3, 1}},
+		{name: "by bytes", cfg: HTTPSinkConfig{BatchBytes: 24, FlushInterval: time.Hour}, lines: 7, flush: true, want: []int{3, 3, 1}}, // 8 bytes per line
+		{name: "by interval", cfg: HTTPSinkConfig{FlushInterval: 10 * time.Millisecond}, lines: 4, want: []int{4}},
+		{name: "flush with nothing written", cfg: HTTPSinkConfig{}, lines: 0, flush: true, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := newSinkServer(t)
+			tt.cfg.URL = ss.URL
+			s := NewHTTPSink(tt.cfg)
+			defer s.Close(context.Background())
+
+			want := writeLines(t, s, 0, tt.lines)
+			if tt.flush {
+				if err := s.Flush(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				waitFor(t, func() bool { return len(ss.requests()) > 0 })
+			}
+			if got := ss.batchSizes(); !slices.Equal(got, tt.want) {
+				t.Errorf("batches = %v, want %v", got, tt.want)
+			}
+			var got []string
+			for _, r := range ss.requests() {
+				got = append(got, r.lines...)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("received %v, want %v", got, want)
+			}
+			if st := s.Stats(); st.Sent != uint64(tt.lines) {
+				t.Errorf("Stats() = %+v, want %d sent", st, tt.lines)
+			}
+		})
+	}
+}
+
+func TestHTTPSinkRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		gzip bool
+	}{
+		{name: "plain"},
+		{name: "gzip", gzip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := newSinkServer(t)
+			s := NewHTTPSink(HTTPSinkConfig{URL: ss.URL, APIKey: "k3y", Headers: map[string]string{"X-Tenant": "acme"}, Gzip: tt.gzip})
+			defer s.Close(context.Background())
+			// Lines without a trailing newline are still separated.
+			_, _ = s.Write([]byte(`{"a":1}`))
+			_, _ = s.Write([]byte(`{"b":2}` + "\n"))
+			if err := s.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := ss.requests()
+			if len(reqs) != 1 {
+				t.Fatalf("got %d requests, want 1", len(reqs))
+			}
+			h := reqs[0].header
+			wantEnc := ""
+			if tt.gzip {
+				wantEnc = "gzip"
+			}
+			if h.Get("Content-Type") != "application/x-ndjson" || h.Get("Authorization") != "Bearer k3y" ||
+				h.Get("X-Tenant") != "acme" || h.Get("Content-Encoding") != wantEnc {
+				t.Errorf("headers = %v", h)
+			}
+			if want := []string{`{"a":1}`, `{"b":2}`}; !slices.Equal(reqs[0].lines, want) {
+				t.Errorf("lines = %q, want %q", reqs[0].lines, want)
+			}
+		})
+	}
+}
+
+func TestHTTPSinkRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		want         SinkStats
+		wantErr      bool
+	}{
+		{name: "ok", statuses: nil, wantAttempts: 1, want: SinkStats{Sent: 2}},
+		{name: "server error then ok", statuses: []int{503, 200}, wantAttempts: 2, want: SinkStats{Sent: 2}},
+		{name: "throttled then ok", statuses: []int{429, 502, 200}, wantAttempts: 3, want: SinkStats{Sent: 2}},
+		{name: "retries exhausted", statuses: []int{500, 500, 500, 500}, wantAttempts: 3, want: SinkStats{Failed: 2}, wantErr: true},
+		{name: "client error not retried", statuses: []int{400}, wantAttempts: 1, want: SinkStats{Failed: 2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := newSinkServer(t, tt.statuses...)
+			s := NewHTTPSink(HTTPSinkConfig{URL: ss.URL, MaxRetries: 2, RetryBackoff: time.Millisecond})
+			defer s.Close(context.Background())
+
+			writeLines(t, s, 0, 2)
+			err := s.Flush(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Flush() = %v, want error %v", err, tt.wantErr)
+			}
+			if got := len(ss.requests()); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			if st := s.Stats(); st != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", st, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPSinkFlushContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := NewHTTPSink(HTTPSinkConfig{URL: srv.URL, Timeout: time.Minute})
+	defer s.Close(context.Background())
+	writeLines(t, s, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Flush() = %v, want deadline exceeded", err)
+	}
+}
+
+func TestHTTPSinkDrops(t *testing.T) {
+	ss := newSinkServer(t)
+	// Not started yet, so the one-line buffer fills up.
+	s := newHTTPSink(HTTPSinkConfig{URL: ss.URL, Buffer: 1})
+	writeLines(t, s, 0, 3)
+	if st := s.Stats(); st.Dropped != 2 {
+		t.Errorf("Stats() = %+v, want 2 dropped", st)
+	}
+	go s.loop()
+
+	ctx := context.Background()
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+	if err := s.Flush(ctx); !errors.Is(err, errSinkClosed) {
+		t.Errorf("Flush() after Close = %v", err)
+	}
+	writeLines(t, s, 3, 4)
+	if st := s.Stats(); st != (SinkStats{Sent: 1, Dropped: 3}) {
+		t.Errorf("Stats() = %+v, want 1 sent (on Close) and 3 dropped", st)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	ss := newSinkServer(t, http.StatusBadRequest)
+	s := NewHTTPSink(HTTPSinkConfig{URL: ss.URL, BatchSize: 2})
+	x := NewWithWriter(s, Options{Level: "info"})
+	ctx := context.Background()
+	x.Info(ctx).Msg("rejected")
+	x.Info(ctx).Msg("rejected")
+	x.Info(ctx).Msg("sent")
+	_ = x.Close(ctx) // may report the rejected batch
+	x.Info(ctx).Msg("after close")
+
+	want := `
+# HELP log_sink_lines_total Log lines handled by remote log sinks, by outcome (sent, dropped, failed, spooled).
+# TYPE log_sink_lines_total counter
+log_sink_lines_total{result="dropped",sink="http"} 1
+log_sink_lines_total{result="failed",sink="http"} 2
+log_sink_lines_total{result="sent",sink="http"} 1
+log_sink_lines_total{result="spooled",sink="http"} 0
+`
+	if err := testutil.CollectAndCompare(x.Collector(), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// levels holds the base level and per-component overrides for one root
+// logger and everything derived from it via With/Named.
+type levels struct {
+	mu         sync.RWMutex
+	base       zerolog.Level
+	components map[string]zerolog.Level
+	reverts    map[string]*revert
+}
+
+// revert is a pending restore of a component's level from before the first
+// of possibly several stacked TTL sets.
+type revert struct {
+	timer *time.Timer
+	prev  zerolog.Level
+	had   bool // prev was an override (always true for the base level)
+}
+
+func newLevels(base zerolog.Level) *levels {
+	return &levels{
+		base:       base,
+		components: map[string]zerolog.Level{},
+		reverts:    map[string]*revert{},
+	}
+}
+
+// of returns the effective level for a dotted component name: the most
+// specific override ("pgxkit.pool", then "pgxkit"), else the base level.
+func (lv *levels) of(name string) zerolog.Level {
+	lv.mu.RLock()
+	defer lv.mu.RUnlock()
+	for name != "" {
+		if l, ok := lv.components[name]; ok {
+			return l
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return lv.base
+}
+
+// set changes the level for component ("" = base). With ttl > 0 the previous
+// setting is restored once ttl elapses; a TTL set while another is pending
+// restores the setting from before the first one.
+func (lv *levels) set(component string, l zerolog.Level, ttl time.Duration) {
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+
+	prev, had := lv.base, true
+	if component != "" {
+		prev, had = lv.components[component]
+	}
+	if r, ok := lv.reverts[component]; ok {
+		r.timer.Stop()
+		delete(lv.reverts, component)
+		prev, had = r.prev, r.had
+	}
+	lv.put(component, l, true)
+
+	if ttl > 0 {
+		r := &revert{prev: prev, had: had}
+		r.timer = time.AfterFunc(ttl, func() {
+			lv.mu.Lock()
+			defer lv.mu.Unlock()
+			if lv.reverts[component] != r { // superseded by a later set
+				return
+			}
+			delete(lv.reverts, component)
+			lv.put(component, r.prev, r.had)
+		})
+		lv.reverts[component] = r
+	}
+}
+
+// put stores (or, when !ok, removes) an override; callers hold mu.
+func (lv *levels) put(component string, l zerolog.Level, ok bool) {
+	switch {
+	case component == "":
+		lv.base = l
+	case ok:
+		lv.components[component] = l
+	default:
+		delete(lv.components, component)
+	}
+}
+
+func (lv *levels) reset(component string) {
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+	if r, ok := lv.reverts[component]; ok {
+		r.timer.Stop()
+		delete(lv.reverts, component)
+	}
+	delete(lv.components, component)
+}
+
+// LevelState is the JSON shape served by LevelHandler.
+type LevelState struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+func (lv *levels) state() LevelState {
+	lv.mu.RLock()
+	defer lv.mu.RUnlock()
+	st := LevelState{Level: lv.base.String(), Components: map[string]string{}}
+	for name, l := range lv.components {
+		st.Components[name] = l.String()
+	}
+	return st
+}
+
+// ---------- Runtime API ----------
+
+func (x *Loggerx) level() zerolog.Level { return x.lv.of(x.name) }
+
+// Enabled reports whether events at lvl would be written by this logger.
+func (x *Loggerx) Enabled(lvl zerolog.Level) bool {
+	l := x.level()
+	return l != zerolog.Disabled && lvl >= l
+}
+
+// SetLevel changes the level of a component ("" for the base level) on this
+// logger's whole tree. With ttl > 0 the previous level comes back after ttl,
+// which is handy for "debug pgxkit for 10 minutes" in production.
+func (x *Loggerx) SetLevel(component string, lvl zerolog.Level, ttl time.Duration) {
+	x.lv.set(component, lvl, ttl)
+}
+
+// ResetLevel removes a component override so it follows the base level again.
+func (x *Loggerx) ResetLevel(component string) { x.lv.reset(component) }
+
+// Levels reports the base level and all component overrides.
+func (x *Loggerx) Levels() LevelState { return x.lv.state() }
+
+// LevelHandler serves GET/PUT for runtime level control, e.g. on
+// /debug/loglevel behind admin auth:
+//
+//	GET                                           -> {"level":"info","components":{"pgxkit":"debug"}}
+//	PUT {"component":"pgxkit","level":"debug","ttl":"10m"}
+//	PUT {"level":"warn"}                          -> base level
+//	PUT {"component":"pgxkit","level":""}         -> remove the override
+func LevelHandler(x *Loggerx) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req struct {
+				Component string `json:"component"`
+				Level     string `json:"level"`
+				TTL       string `json:"ttl"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			var ttl time.Duration
+			if req.TTL != "" {
+				d, err := time.ParseDuration(req.TTL)
+				if err != nil || d < 0 {
+					http.Error(w, "invalid ttl", http.StatusBadRequest)
+					return
+				}
+				ttl = d
+			}
+			if req.Level == "" && req.Component != "" {
+				x.ResetLevel(req.Component)
+				break
+			}
+			lvl, err := ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			x.SetLevel(req.Component, lvl, ttl)
+			x.Warn(r.Context()).Str("target_component", req.Component).Str("level", lvl.String()).
+				Dur("ttl", ttl).Msg("log level changed")
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(x.Levels())
+	})
+}
@@ -0,0 +1,238 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    zerolog.Level
+		wantErr bool
+	}{
+		{in: "", want: zerolog.InfoLevel},
+		{in: "debug", want: zerolog.DebugLevel},
+		{in: " WARNING ", want: zerolog.WarnLevel},
+		{in: "err", want: zerolog.ErrorLevel},
+		{in: "off", want: zerolog.Disabled},
+		{in: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLevelsOf(t *testing.T) {
+	lv := newLevels(zerolog.InfoLevel)
+	lv.set("pgxkit", zerolog.DebugLevel, 0)
+	lv.set("pgxkit.pool", zerolog.ErrorLevel, 0)
+	lv.set("http", zerolog.WarnLevel, 0)
+
+	tests := []struct {
+		name string
+		want zerolog.Level
+	}{
+		{name: "", want: zerolog.InfoLevel},
+		{name: "pgxkit", want: zerolog.DebugLevel},
+		{name: "pgxkit.pool", want: zerolog.ErrorLevel},
+		{name: "pgxkit.pool.conn", want: zerolog.ErrorLevel},
+		{name: "pgxkit.tx", want: zerolog.DebugLevel},
+		{name: "pgx", want: zerolog.InfoLevel}, // not a dotted child of pgxkit
+		{name: "httpserver", want: zerolog.InfoLevel},
+	}
+	for _, tt := range tests {
+		if got := lv.of(tt.name); got != tt.want {
+			t.Errorf("of(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	var a, b lineBuffer
+	la := NewWithWriter(&a, Options{Level: "warn", ComponentLevels: map[string]string{"pgxkit": "debug"}})
+	lb := NewWithWriter(&b, Options{Level: "debug"})
+	ctx := context.Background()
+
+	la.Info(ctx).Msg("dropped")
+	la.Named("pgxkit").Debug(ctx).Msg("pgxkit debug")
+	la.With("k", "v").Named("pgxkit").Named("pool").Debug(ctx).Msg("pool debug")
+	la.Named("http").Info(ctx).Msg("dropped")
+	lb.Debug(ctx).Msg("other instance")
+
+	if got := a.get(); len(got) != 2 || !strings.Contains(got[0], `"component":"pgxkit"`) || !strings.Contains(got[1], `"component":"pgxkit.pool"`) {
+		t.Errorf("first logger wrote %q", got)
+	}
+	if got := b.get(); len(got) != 1 {
+		t.Errorf("second logger wrote %q; levels leak between instances", got)
+	}
+
+	// A runtime change reaches loggers derived before it.
+	child := la.Named("http")
+	la.SetLevel("http", zerolog.DebugLevel, 0)
+	if !child.Enabled(zerolog.DebugLevel) {
+		t.Error("SetLevel did not reach an existing sub-logger")
+	}
+	child.ResetLevel("http")
+	if child.Enabled(zerolog.InfoLevel) {
+		t.Error("after ResetLevel http should follow the warn base level")
+	}
+	la.SetLevel("", zerolog.Disabled, 0)
+	if la.Enabled(zerolog.ErrorLevel) {
+		t.Error("disabled logger reports error as enabled")
+	}
+}
+
+func TestSetLevelTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		component string
+		initial   map[string]zerolog.Level // overrides before the timed set
+		want      zerolog.Level            // level of component after revert
+		wantSet   bool                     // override still present after revert
+	}{
+		{name: "base level", component: "", want: zerolog.InfoLevel},
+		{name: "new override removed", component: "pgxkit", want: zerolog.InfoLevel},
+		{name: "previous override restored", component: "pgxkit", initial: map[string]zerolog.Level{"pgxkit": zerolog.WarnLevel}, want: zerolog.WarnLevel, wantSet: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lv := newLevels(zerolog.InfoLevel)
+			for name, l := range tt.initial {
+				lv.set(name, l, 0)
+			}
+			lv.set(tt.component, zerolog.DebugLevel, 20*time.Millisecond)
+			if got := lv.of(tt.component); got != zerolog.DebugLevel {
+				t.Fatalf("level = %v, want debug", got)
+			}
+			waitFor(t, func() bool { return lv.of(tt.component) != zerolog.DebugLevel })
+			if got := lv.of(tt.component); got != tt.want {
+				t.Errorf("reverted to %v, want %v", got, tt.want)
+			}
+			if _, ok := lv.state().Components[tt.component]; ok != tt.wantSet {
+				t.Errorf("override present = %v, want %v", ok, tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestSetLevelTTLSuperseded(t *testing.T) {
+	lv := newLevels(zerolog.InfoLevel)
+	lv.set("pgxkit", zerolog.DebugLevel, 10*time.Millisecond)
+	lv.set("pgxkit", zerolog.ErrorLevel, 0)
+	time.Sleep(50 * time.Millisecond)
+	if got := lv.of("pgxkit"); got != zerolog.ErrorLevel {
+		t.Errorf("level = %v; the timer of a superseded set must not fire", got)
+	}
+
+	lv.set("pgxkit", zerolog.DebugLevel, 10*time.Millisecond)
+	lv.reset("pgxkit")
+	lv.set("pgxkit", zerolog.TraceLevel, 0)
+	time.Sleep(50 * time.Millisecond)
+	if got := lv.of("pgxkit"); got != zerolog.TraceLevel {
+		t.Errorf("level = %v; reset must stop the revert timer", got)
+	}
+}
+
+func TestSetLevelTTLStacked(t *testing.T) {
+	tests := []struct {
+		name      string
+		component string
+		initial   map[string]zerolog.Level
+		want      zerolog.Level
+	}{
+		{name: "base level", component: "", want: zerolog.InfoLevel},
+		{name: "no previous override", component: "pgx", want: zerolog.InfoLevel},
+		{name: "previous override", component: "pgx", initial: map[string]zerolog.Level{"pgx": zerolog.WarnLevel}, want: zerolog.WarnLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lv := newLevels(zerolog.InfoLevel)
+			for name, l := range tt.initial {
+				lv.set(name, l, 0)
+			}
+			// "debug for 10m", then "trace for 5m" before the first expires:
+			// both come back to the level from before the first.
+			lv.set(tt.component, zerolog.DebugLevel, time.Hour)
+			lv.set(tt.component, zerolog.TraceLevel, 20*time.Millisecond)
+			waitFor(t, func() bool { return lv.of(tt.component) != zerolog.TraceLevel })
+			if got := lv.of(tt.component); got != tt.want {
+				t.Errorf("reverted to %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		want       LevelState
+		logged     bool // a "log level changed" line is written
+	}{
+		{name: "get", method: http.MethodGet, wantStatus: http.StatusOK, want: LevelState{Level: "info", Components: map[string]string{"pgxkit": "debug"}}},
+		{name: "set base", method: http.MethodPut, body: `{"level":"warn"}`, wantStatus: http.StatusOK, want: LevelState{Level: "warn", Components: map[string]string{"pgxkit": "debug"}}, logged: true},
+		{name: "set component", method: http.MethodPut, body: `{"component":"http","level":"error","ttl":"1h"}`, wantStatus: http.StatusOK, want: LevelState{Level: "info", Components: map[string]string{"pgxkit": "debug", "http": "error"}}, logged: true},
+		{name: "post accepted", method: http.MethodPost, body: `{"component":"http","level":"trace"}`, wantStatus: http.StatusOK, want: LevelState{Level: "info", Components: map[string]string{"pgxkit": "debug", "http": "trace"}}, logged: true},
+		{name: "remove override", method: http.MethodPut, body: `{"component":"pgxkit","level":""}`, wantStatus: http.StatusOK, want: LevelState{Level: "info"}},
+		{name: "bad json", method: http.MethodPut, body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "bad level", method: http.MethodPut, body: `{"level":"loud"}`, wantStatus: http.StatusBadRequest},
+		{name: "bad ttl", method: http.MethodPut, body: `{"level":"debug","ttl":"-1m"}`, wantStatus: http.StatusBadRequest},
+		{name: "method", method: http.MethodDelete, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lineBuffer
+			x := NewWithWriter(&out, Options{Level: "info", ComponentLevels: map[string]string{"pgxkit": "debug"}})
+			rec := httptest.NewRecorder()
+			LevelHandler(x.Named("admin")).ServeHTTP(rec, httptest.NewRequest(tt.method, "/debug/loglevel", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got LevelState
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Level != tt.want.Level || !maps.Equal(got.Components, tt.want.Components) {
+				t.Errorf("state = %+v, want %+v", got, tt.want)
+			}
+			lines := out.get()
+			if logged := len(lines) > 0; logged != tt.logged {
+				t.Fatalf("logged = %v, want %v", logged, tt.logged)
+			}
+			// The handler's own component stays the only "component" key.
+			if tt.logged && (strings.Count(lines[0], `"component":`) != 1 || !strings.Contains(lines[0], `"component":"admin"`) ||
+				!strings.Contains(lines[0], `"target_component":`)) {
+				t.Errorf("logged %s", lines[0])
+			}
+		})
+	}
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -2,12 +2,16 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"io"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"fmt"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 	"os"
 
 	"time"
@@ -20,39 +24,99 @@ type Options struct {
 	// Format/Mode
 	Dev bool // pretty console when true; JSON otherwise
 
+	// Level is this logger's base level (default $LOG_LEVEL, else info).
+	// ComponentLevels overrides it for named sub-loggers, e.g. {"pgxkit": "debug"}.
+	// Both can be changed at runtime; see SetLevel and LevelHandler.
+	Level           string
+	ComponentLevels map[string]string
+
 	// Optional remote HTTP sink (non-blocking, best-effort)
 	EnableHTTPSink bool
 	HTTPURL        string // e.g. http://logger-service.infra.svc:8080/api/v1/logs
 	HTTPAPIKey     string
 	HTTPTimeout    time.Duration // default 1s
 	Buffer         int           // default 1024 log lines in memory
+
+	// Optional log file with rotation and retention (File.Path "" disables).
+	File FileSinkConfig
+
+	// Optional RFC 5424 syslog sink (Syslog.Addr "" disables).
+	Syslog SyslogConfig
+
+	// Optional OTLP/HTTP logs sink, e.g. http://otel-collector:4318. Uses
+	// the batching settings below; OTLPResource is typically
+	// otelx.ResourceAttributes(...) so logs match the service's traces.
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+	OTLPResource map[string]string
+
+	// Sink batching; see HTTPSinkConfig for defaults.
+	HTTPBatchSize     int
+	HTTPFlushInterval time.Duration
+	HTTPGzip          bool
+
+	// HTTPSpoolDir keeps lines the sink can't deliver on disk and replays
+	// them in order later, even after a restart; see SpoolConfig.
+	HTTPSpoolDir string
+
+	// Redaction runs on every line before any writer (stdout, console and
+	// remote sinks). RedactFields and RedactPatterns extend
+	// DefaultRedactFields and DefaultRedactPatterns.
+	RedactFields     []string
+	RedactPatterns   []*regexp.Regexp
+	DisableRedaction bool
+
+	// Sampling keeps, per level, the first First events with the same message
+	// per SampleInterval (default 1s), then 1 in Thereafter. Levels without a
+	// rule are not sampled.
+	Sampling       map[zerolog.Level]SampleRule
+	SampleInterval time.Duration
+
+	// Dedupe collapses identical error lines (ignoring ts and the trace,
+	// span, tenant, user and request ID fields) within this window into the
+	// first one plus a line carrying "repeated":N. 0 disables.
+	Dedupe time.Duration
 }
 
 type Loggerx struct {
-	l zerolog.Logger
+	l    zerolog.Logger
+	name string  // component name set by Named; "" for the root
+	lv   *levels // shared by every logger derived from the same root
+
+	sinks []Sink // buffered writers to flush on shutdown
+}
+
+var globalsOnce sync.Once
+
+// setGlobals configures zerolog's package-level field names and caller
+// format. zerolog has no per-logger equivalent, so this happens once per
+// process; levels are per instance (see levels).
+func setGlobals() {
+	globalsOnce.Do(func() {
+		zerolog.TimeFieldFormat = time.RFC3339
+		zerolog.TimestampFieldName = "ts"
+		zerolog.LevelFieldName = "lvl"
+		zerolog.MessageFieldName = "msg"
+		zerolog.CallerFieldName = "caller"
+		// Trim caller paths like "../../../../go/pkg/mod/.../file.go:123" -> "pkg/file.go:123"
+		zerolog.CallerMarshalFunc = func(_ uintptr, file string, line int) string {
+			parts := strings.Split(file, "/")
+			if len(parts) > 2 {
+				file = strings.Join(parts[len(parts)-2:], "/")
+			}
+			return fmt.Sprintf("%s:%d", file, line)
+		}
+	})
 }
 
 // NewWithOptions is the preferred constructor.
 func NewWithOptions(opts Options) *Loggerx {
-	// ---------- Global zerolog config ----------
-	// Level (default Info; override via LOG_LEVEL: debug|info|warn|error)
-	zerolog.SetGlobalLevel(parseLevel(getEnv("LOG_LEVEL", "info")))
-
-	// ---- Global zerolog field names and caller shortener ----
-	zerolog.TimeFieldFormat = time.RFC3339
-	zerolog.TimestampFieldName = "ts"
-	zerolog.LevelFieldName = "lvl"
-	zerolog.MessageFieldName = "msg"
-	zerolog.CallerFieldName = "caller"
-	// Trim caller paths like "../../../../go/pkg/mod/.../file.go:123" -> "pkg/file.go:123"
-	zerolog.CallerMarshalFunc = func(_ uintptr, file string, line int) string {
-		parts := strings.Split(file, "/")
-		if len(parts) > 2 {
-			file = strings.Join(parts[len(parts)-2:], "/")
-		}
-		return fmt.Sprintf("%s:%d", file, line)
-	}
+	setGlobals()
+
+	lv := optLevels(opts)
+
 	writers := make([]io.Writer, 0, 2)
+	var sinks []Sink
 
 	// Always keep stdout for kubectl logs / local dev.
 	if opts.Dev {
@@ -80,22 +144,98 @@ func NewWithOptions(opts Options) *Loggerx {
 	// Optional remote sink (HTTP).
 	if opts.EnableHTTPSink && opts.HTTPURL != "" {
 		hw := NewHTTPSink(HTTPSinkConfig{
-			URL:     opts.HTTPURL,
-			APIKey:  opts.HTTPAPIKey,
-			Timeout: firstNonZero(opts.HTTPTimeout, time.Second),
-			Buffer:  firstNonZeroInt(opts.Buffer, 1024),
+			URL:           opts.HTTPURL,
+			APIKey:        opts.HTTPAPIKey,
+			Timeout:       firstNonZero(opts.HTTPTimeout, time.Second),
+			Buffer:        firstNonZeroInt(opts.Buffer, 1024),
+			BatchSize:     opts.HTTPBatchSize,
+			FlushInterval: opts.HTTPFlushInterval,
+			Gzip:          opts.HTTPGzip,
+			Spool:         SpoolConfig{Dir: opts.HTTPSpoolDir},
 		})
 		writers = append(writers, hw) // tee: stdout + remote
+		sinks = append(sinks, hw)
 	}
 
-	mw := io.MultiWriter(writers...)
+	// Optional file sink (VMs); errors leave stdout and the others working.
+	if opts.File.Path != "" {
+		fw, err := NewFileSink(opts.File)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v; continuing without file sink\n", err)
+		} else {
+			writers = append(writers, fw)
+			sinks = append(sinks, fw)
+		}
+	}
+
+	// Optional syslog sink (on-prem collectors).
+	if opts.Syslog.Addr != "" {
+		sw, err := NewSyslogSink(opts.Syslog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v; continuing without syslog sink\n", err)
+		} else {
+			writers = append(writers, sw)
+			sinks = append(sinks, sw)
+		}
+	}
 
-	// Caller points to the app callsite (skip wrappers).
+	// Optional remote sink (OTLP).
+	if opts.OTLPEndpoint != "" {
+		ow := NewOTLPSink(OTLPSinkConfig{
+			HTTPSinkConfig: HTTPSinkConfig{
+				URL:           opts.OTLPEndpoint,
+				Headers:       opts.OTLPHeaders,
+				Timeout:       firstNonZero(opts.HTTPTimeout, time.Second),
+				Buffer:        firstNonZeroInt(opts.Buffer, 1024),
+				BatchSize:     opts.HTTPBatchSize,
+				FlushInterval: opts.HTTPFlushInterval,
+				Gzip:          opts.HTTPGzip,
+			},
+			Resource: opts.OTLPResource,
+		})
+		writers = append(writers, ow)
+		sinks = append(sinks, ow)
+	}
 
-	base := zerolog.New(mw).With().Caller().Timestamp().CallerWithSkipFrameCount(2).Logger()
+	x := newLoggerx(io.MultiWriter(writers...), opts, lv)
+	x.sinks = sinks
 	// One-time sanity probe so you SEE something if wiring is correct.
-	base.Debug().Str("dev", strconv.FormatBool(opts.Dev)).Msg("logger online")
-	return &Loggerx{l: base}
+	x.Debug(context.Background()).Str("dev", strconv.FormatBool(opts.Dev)).Msg("logger online")
+	return x
+}
+
+// NewWithWriter builds a Loggerx that writes only to w: stdout and the sinks
+// configured in opts are skipped, levels, redaction and sampling apply. If w
+// is a Sink, Flush and Close reach it.
+func NewWithWriter(w io.Writer, opts Options) *Loggerx {
+	setGlobals()
+	x := newLoggerx(w, opts, optLevels(opts))
+	if s, ok := w.(Sink); ok {
+		x.sinks = []Sink{s}
+	}
+	return x
+}
+
+// newLoggerx wraps w with redaction and builds the root logger. The caller
+// field is added per event (see event) so adapters can report their own.
+func newLoggerx(w io.Writer, opts Options, lv *levels) *Loggerx {
+	if !opts.DisableRedaction {
+		w = newRedactor(w, opts.RedactFields, opts.RedactPatterns)
+	}
+	if len(opts.Sampling) > 0 || opts.Dedupe > 0 {
+		w = newSampler(w, opts.Sampling, opts.SampleInterval, opts.Dedupe)
+	}
+	return &Loggerx{l: zerolog.New(w).With().Timestamp().Logger(), lv: lv}
+}
+
+// optLevels reads Level (default Info; override via Options.Level or
+// LOG_LEVEL: debug|info|warn|error) and ComponentLevels.
+func optLevels(opts Options) *levels {
+	lv := newLevels(parseLevel(firstNonEmpty(opts.Level, getEnv("LOG_LEVEL", "info"))))
+	for name, l := range opts.ComponentLevels {
+		lv.set(name, parseLevel(l), 0)
+	}
+	return lv
 }
 
 // New keeps backward compatibility with previous code paths.
@@ -103,17 +243,70 @@ func New(dev bool) *Loggerx { return NewWithOptions(Options{Dev: dev}) }
 
 // With adds structured fields.
 func (x *Loggerx) With(kv ...interface{}) *Loggerx {
-	return &Loggerx{l: x.l.With().Fields(kv).Logger()}
+	return &Loggerx{l: x.l.With().Fields(kv).Logger(), name: x.name, lv: x.lv, sinks: x.sinks}
+}
+
+// Named returns a sub-logger for a component (adds a "component" field).
+// Its level can be overridden separately: SetLevel("pgxkit", ...). Nested
+// names are dotted ("pgxkit.pool") and fall back to their parent's override.
+func (x *Loggerx) Named(name string) *Loggerx {
+	if x.name != "" {
+		name = x.name + "." + name
+	}
+	return &Loggerx{l: x.l, name: name, lv: x.lv, sinks: x.sinks}
 }
 
 // Accessors (context-aware): they attach trace/tenant/user if present in ctx.
-func (x *Loggerx) Info(ctx context.Context) *zerolog.Event  { return bindCtx(x.l, ctx).Info() }
-func (x *Loggerx) Error(ctx context.Context) *zerolog.Event { return bindCtx(x.l, ctx).Error() }
-func (x *Loggerx) Warn(ctx context.Context) *zerolog.Event  { return bindCtx(x.l, ctx).Warn() }
-func (x *Loggerx) Debug(ctx context.Context) *zerolog.Event { return bindCtx(x.l, ctx).Debug() }
+// Events below the effective level are nil, which zerolog treats as a no-op.
+func (x *Loggerx) Info(ctx context.Context) *zerolog.Event { return x.event(ctx, zerolog.InfoLevel, 1) }
+func (x *Loggerx) Error(ctx context.Context) *zerolog.Event {
+	return x.event(ctx, zerolog.ErrorLevel, 1)
+}
+func (x *Loggerx) Warn(ctx context.Context) *zerolog.Event { return x.event(ctx, zerolog.WarnLevel, 1) }
+func (x *Loggerx) Debug(ctx context.Context) *zerolog.Event {
+	return x.event(ctx, zerolog.DebugLevel, 1)
+}
+
+// event starts an event at lvl. skip is the number of frames between event's
+// caller and the app callsite reported as "caller"; < 0 omits the field.
+func (x *Loggerx) event(ctx context.Context, lvl zerolog.Level, skip int) *zerolog.Event {
+	if !x.Enabled(lvl) {
+		return nil
+	}
+	e := bindCtx(x.l, ctx).WithLevel(lvl)
+	if skip >= 0 {
+		e = e.Caller(skip + 1)
+	}
+	if x.name != "" {
+		e = e.Str("component", x.name)
+	}
+	return e
+}
 
-// Logger returns the underlying zerolog (advanced usage).
-func (x *Loggerx) Logger() zerolog.Logger { return x.l }
+// Logger returns the underlying zerolog (advanced usage), filtered at the
+// current effective level. Later SetLevel calls don't affect the copy.
+func (x *Loggerx) Logger() zerolog.Logger {
+	return x.l.With().Caller().Logger().Level(x.level())
+}
+
+// Flush delivers lines buffered by remote sinks. Call it before exiting, or
+// let httpserver.Server.Start do it on shutdown.
+func (x *Loggerx) Flush(ctx context.Context) error {
+	var errs []error
+	for _, s := range x.sinks {
+		errs = append(errs, s.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Close flushes and stops remote sinks; stdout keeps working.
+func (x *Loggerx) Close(ctx context.Context) error {
+	var errs []error
+	for _, s := range x.sinks {
+		errs = append(errs, s.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
 
 // ---------- Context helpers (stable API you can use anywhere) ----------
 
@@ -123,6 +316,21 @@ const (
 	ctxKeyTraceID  ctxKey = "trace_id"
 	ctxKeyTenantID ctxKey = "tenant_id"
 	ctxKeyUserID   ctxKey = "user_id"
+	ctxKeyFields   ctxKey = "fields"
+)
+
+// Names of the fields bound from the context. Like zerolog's *FieldName
+// variables, set them once at startup if your pipeline expects other names.
+var (
+	TraceIDFieldName    = "trace_id"
+	SpanIDFieldName     = "span_id"
+	TraceFlagsFieldName = "trace_flags"
+	TenantIDFieldName   = "tenant_id"
+	UserIDFieldName     = "user_id"
+
+	// RequestIDFieldName isn't bound by the logger, but Dedupe ignores it
+	// like the fields above.
+	RequestIDFieldName = "request_id"
 )
 
 // WithTraceID attaches a trace ID into context.
@@ -136,6 +344,36 @@ func WithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, ctxKeyUserID, userID)
 }
 
+// WithFields adds request-scoped key/value pairs that every log call with
+// this ctx (or one derived from it) includes, e.g.
+// ctx = logger.WithFields(ctx, "order_id", id). A repeated key replaces the
+// earlier value.
+func WithFields(ctx context.Context, kv ...any) context.Context {
+	old := FieldsFrom(ctx)
+	fields := make([]any, 0, len(old)+len(kv))
+	fields = append(fields, old...)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		replaced := false
+		for j := 0; j+1 < len(fields); j += 2 {
+			if fields[j] == key {
+				fields[j+1], replaced = kv[i+1], true
+				break
+			}
+		}
+		if !replaced {
+			fields = append(fields, key, kv[i+1])
+		}
+	}
+	return context.WithValue(ctx, ctxKeyFields, fields)
+}
+
+// FieldsFrom returns the pairs added by WithFields (nil if none).
+func FieldsFrom(ctx context.Context) []any {
+	v, _ := ctx.Value(ctxKeyFields).([]any)
+	return v
+}
+
 func TraceIDFrom(ctx context.Context) (string, bool) {
 	v, ok := ctx.Value(ctxKeyTraceID).(string)
 	return v, ok
@@ -156,19 +394,37 @@ func bindCtx(l zerolog.Logger, ctx context.Context) *zerolog.Logger {
 		return &l
 	}
 	ev := l.With()
+	// An explicit WithTraceID wins; otherwise the active OTel span supplies it.
+	sc := trace.SpanContextFromContext(ctx)
 	if v, ok := TraceIDFrom(ctx); ok && v != "" {
-		ev = ev.Str("trace_id", v)
+		ev = ev.Str(TraceIDFieldName, v)
+	} else if sc.IsValid() {
+		ev = ev.Str(TraceIDFieldName, sc.TraceID().String())
+	}
+	if sc.IsValid() {
+		ev = ev.Str(SpanIDFieldName, sc.SpanID().String()).
+			Str(TraceFlagsFieldName, sc.TraceFlags().String())
 	}
 	if v, ok := TenantIDFrom(ctx); ok && v != "" {
-		ev = ev.Str("tenant_id", v)
+		ev = ev.Str(TenantIDFieldName, v)
 	}
 	if v, ok := UserIDFrom(ctx); ok && v != "" {
-		ev = ev.Str("user_id", v)
+		ev = ev.Str(UserIDFieldName, v)
+	}
+	if kv := FieldsFrom(ctx); len(kv) > 0 {
+		ev = ev.Fields(kv)
 	}
 	ll := ev.Logger()
 	return &ll
 }
 
+func firstNonEmpty(v, d string) string {
+	if v == "" {
+		return d
+	}
+	return v
+}
+
 func firstNonZero(v, d time.Duration) time.Duration {
 	if v == 0 {
 		return d
@@ -191,24 +447,33 @@ func getEnv(k, def string) string {
 }
 
 func parseLevel(s string) zerolog.Level {
+	if l, err := ParseLevel(s); err == nil {
+		return l
+	}
+	return zerolog.InfoLevel
+}
+
+// ParseLevel accepts trace|debug|info|warn|error|fatal|panic|disabled (and
+// common aliases such as "warning" or "off"); "" means info.
+func ParseLevel(s string) (zerolog.Level, error) {
 	switch strings.ToLower(strings.TrimSpace(s)) {
 	case "trace":
-		return zerolog.TraceLevel
+		return zerolog.TraceLevel, nil
 	case "debug":
-		return zerolog.DebugLevel
+		return zerolog.DebugLevel, nil
 	case "info", "":
-		return zerolog.InfoLevel
+		return zerolog.InfoLevel, nil
 	case "warn", "warning":
-		return zerolog.WarnLevel
+		return zerolog.WarnLevel, nil
 	case "error", "err":
-		return zerolog.ErrorLevel
+		return zerolog.ErrorLevel, nil
 	case "fatal":
-		return zerolog.FatalLevel
+		return zerolog.FatalLevel, nil
 	case "panic":
-		return zerolog.PanicLevel
+		return zerolog.PanicLevel, nil
 	case "disabled", "off", "none":
-		return zerolog.Disabled
+		return zerolog.Disabled, nil
 	default:
-		return zerolog.InfoLevel
+		return zerolog.NoLevel, fmt.Errorf("logger: unknown level %q", s)
 	}
 }
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWithFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls [][]any
+		want  []any
+	}{
+		{name: "none", want: nil},
+		{name: "one call", calls: [][]any{{"order_id", "o-1", "attempt", 2}}, want: []any{"order_id", "o-1", "attempt", 2}},
+		{name: "appended", calls: [][]any{{"a", 1}, {"b", 2}}, want: []any{"a", 1, "b", 2}},
+		{name: "repeated key replaced in place", calls: [][]any{{"a", 1, "b", 2}, {"a", 3}}, want: []any{"a", 3, "b", 2}},
+		{name: "odd pair ignored", calls: [][]any{{"a", 1, "dangling"}}, want: []any{"a", 1}},
+		{name: "keys stringified", calls: [][]any{{7, "x"}}, want: []any{"7", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, kv := range tt.calls {
+				ctx = WithFields(ctx, kv...)
+			}
+			if got := FieldsFrom(ctx); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FieldsFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFieldsDoesNotLeak(t *testing.T) {
+	parent := WithFields(context.Background(), "a", 1)
+	child := WithFields(parent, "a", 2, "b", 3)
+	if got := FieldsFrom(parent); !reflect.DeepEqual(got, []any{"a", 1}) {
+		t.Errorf("parent fields = %v after deriving a child", got)
+	}
+	if got := FieldsFrom(child); !reflect.DeepEqual(got, []any{"a", 2, "b", 3}) {
+		t.Errorf("child fields = %v", got)
+	}
+}
+
+func TestBindCtx(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x0a, 15: 0x01},
+		SpanID:     trace.SpanID{0x0b, 7: 0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	withSpan := trace.ContextWithSpanContext(context.Background(), sc)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]any // bound fields; absent keys must not be set
+	}{
+		{name: "nil context", ctx: nil, want: map[string]any{}},
+		{name: "empty context", ctx: context.Background(), want: map[string]any{}},
+		{
+			name: "explicit IDs",
+			ctx:  WithUserID(WithTenantID(WithTraceID(context.Background(), "t1"), "acme"), "u1"),
+			want: map[string]any{"trace_id": "t1", "tenant_id": "acme", "user_id": "u1"},
+		},
+		{name: "empty IDs skipped", ctx: WithUserID(WithTraceID(context.Background(), ""), ""), want: map[string]any{}},
+		{
+			name: "span context",
+			ctx:  withSpan,
+			want: map[string]any{"trace_id": "0a000000000000000000000000000001", "span_id": "0b00000000000002", "trace_flags": "01"},
+		},
+		{
+			name: "explicit trace ID wins over the span",
+			ctx:  WithTraceID(withSpan, "t1"),
+			want: map[string]any{"trace_id": "t1", "span_id": "0b00000000000002", "trace_flags": "01"},
+		},
+		{
+			name: "context fields",
+			ctx:  WithFields(WithTenantID(context.Background(), "acme"), "order_id", "o-1", "n", 2),
+			want: map[string]any{"tenant_id": "acme", "order_id": "o-1", "n": 2.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lineBuffer
+			x := NewWithWriter(&out, Options{Level: "info"})
+			x.Info(tt.ctx).Msg("m")
+			lines := out.get()
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, want 1", len(lines))
+			}
+			got := decodeLine(t, lines[0])
+			for _, k := range []string{"ts", "lvl", "msg", "caller"} {
+				delete(got, k)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	defer func(trace, span, flags, tenant string) {
+		TraceIDFieldName, SpanIDFieldName, TraceFlagsFieldName, TenantIDFieldName = trace, span, flags, tenant
+	}(TraceIDFieldName, SpanIDFieldName, TraceFlagsFieldName, TenantIDFieldName)
+	TraceIDFieldName, SpanIDFieldName, TraceFlagsFieldName, TenantIDFieldName = "trace.id", "span.id", "trace.flags", "org"
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	ctx := WithTenantID(trace.ContextWithSpanContext(context.Background(), sc), "acme")
+
+	var out lineBuffer
+	NewWithWriter(&out, Options{Level: "info"}).Info(ctx).Msg("m")
+	got := decodeLine(t, out.get()[0])
+	for _, k := range []string{"trace.id", "span.id", "trace.flags", "org"} {
+		if _, ok := got[k]; !ok {
+			t.Errorf("field %q missing from %v", k, got)
+		}
+	}
+	for _, k := range []string{"trace_id", "span_id", "trace_flags", "tenant_id"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("default field %q still written: %v", k, got)
+		}
+	}
+}
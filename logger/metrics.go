@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var sinkLinesDesc = prometheus.NewDesc(
+	"log_sink_lines_total",
+	"Log lines handled by remote log sinks, by outcome (sent, dropped, failed, spooled).",
+	[]string{"sink", "result"}, nil,
+)
+
+// Collector exposes SinkStats of this logger's remote sinks as the counter
+// log_sink_lines_total{sink,result}. Register it once per root logger:
+//
+//	reg.MustRegister(log.Collector())
+func (x *Loggerx) Collector() prometheus.Collector { return sinkCollector(x.sinks) }
+
+type sinkCollector []Sink
+
+func (c sinkCollector) Describe(ch chan<- *prometheus.Desc) { ch <- sinkLinesDesc }
+
+func (c sinkCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c {
+		st, ok := s.(interface{ Stats() SinkStats })
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(s)
+		stats := st.Stats()
+		for _, m := range []struct {
+			result string
+			n      uint64
+		}{{"sent", stats.Sent}, {"dropped", stats.Dropped}, {"failed", stats.Failed}, {"spooled", stats.Spooled}} {
+			ch <- prometheus.MustNewConstMetric(sinkLinesDesc, prometheus.CounterValue, float64(m.n), name, m.result)
+		}
+	}
+}
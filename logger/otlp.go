@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// OTLPSinkConfig configures an OTLP/HTTP logs sink. URL is the collector base
+// (http://otel-collector:4318, "/v1/logs" is appended) or a full logs URL.
+// Batching, gzip, retries and the spool work as for HTTPSink.
+type OTLPSinkConfig struct {
+	HTTPSinkConfig
+
+	// Resource attributes of every record, e.g. from otelx.ResourceAttributes:
+	// {"service.name": "orders", "deployment.environment": "production"}.
+	Resource map[string]string
+}
+
+const otlpScope = "github.com/ranakdinesh/spur/logger"
+
+// NewOTLPSink returns a sink that exports log lines as OTLP log records
+// (JSON encoding). lvl becomes the severity, msg the body, the trace ID, span
+// ID and trace flags fields the record's trace context and every other field
+// an attribute.
+func NewOTLPSink(cfg OTLPSinkConfig) *HTTPSink {
+	if u := strings.TrimRight(cfg.URL, "/"); !strings.HasSuffix(u, "/v1/logs") {
+		cfg.URL = u + "/v1/logs"
+	}
+	s := newHTTPSink(cfg.HTTPSinkConfig)
+	s.name = "otlp"
+	s.contentType = "application/json"
+	resource := make([]otlpKeyValue, 0, len(cfg.Resource))
+	for k, v := range cfg.Resource {
+		resource = append(resource, otlpKeyValue{Key: k, Value: otlpAnyValue{String: &v}})
+	}
+	s.format = func(batch [][]byte) ([]byte, error) { return otlpLogs(resource, batch) }
+	go s.loop()
+	return s
+}
+
+// OTLP/JSON shapes (opentelemetry-proto ExportLogsServiceRequest).
+type (
+	otlpRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpScopeLogs struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string         `json:"timeUnixNano,omitempty"`
+		ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+		SeverityNumber       int            `json:"severityNumber,omitempty"`
+		SeverityText         string         `json:"severityText,omitempty"`
+		Body                 otlpAnyValue   `json:"body"`
+		Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+		TraceID              string         `json:"traceId,omitempty"`
+		SpanID               string         `json:"spanId,omitempty"`
+		Flags                uint32         `json:"flags,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		String *string         `json:"stringValue,omitempty"`
+		Bool   *bool           `json:"boolValue,omitempty"`
+		Int    *string         `json:"intValue,omitempty"`
+		Double *float64        `json:"doubleValue,omitempty"`
+		Array  *otlpArrayValue `json:"arrayValue,omitempty"`
+		KvList *otlpKvList     `json:"kvlistValue,omitempty"`
+	}
+	otlpArrayValue struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+	otlpKvList struct {
+		Values []otlpKeyValue `json:"values"`
+	}
+)
+
+func otlpLogs(resource []otlpKeyValue, batch [][]byte) ([]byte, error) {
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+	sl := otlpScopeLogs{LogRecords: make([]otlpLogRecord, 0, len(batch))}
+	sl.Scope.Name = otlpScope
+	for _, line := range batch {
+		if rec, ok := otlpRecord(line); ok {
+			rec.ObservedTimeUnixNano = observed
+			sl.LogRecords = append(sl.LogRecords, rec)
+		}
+	}
+	return json.Marshal(otlpRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource:  otlpResource{Attributes: resource},
+		ScopeLogs: []otlpScopeLogs{sl},
+	}}})
+}
+
+// otlpRecord maps one JSON log line; field order is kept for attributes.
+func otlpRecord(line []byte) (otlpLogRecord, bool) {
+	var rec otlpLogRecord
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return rec, false
+	}
+	var traceID, spanID string
+	for dec.More() {
+		kt, err := dec.Token()
+		if err != nil {
+			return rec, false
+		}
+		key, _ := kt.(string)
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return rec, false
+		}
+		s, isStr := v.(string)
+		switch {
+		case key == zerolog.LevelFieldName && isStr:
+			rec.SeverityNumber, rec.SeverityText = otlpSeverity(s)
+		case key == zerolog.MessageFieldName && isStr:
+			rec.Body = otlpAnyValue{String: &s}
+		case key == zerolog.TimestampFieldName && isStr:
+			if t, err := time.Parse(zerolog.TimeFieldFormat, s); err == nil {
+				rec.TimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+			}
+		case key == TraceIDFieldName && isHexID(s, 16):
+			traceID = s
+		case key == SpanIDFieldName && isHexID(s, 8):
+			spanID = s
+		case key == TraceFlagsFieldName && isStr:
+			if f, err := strconv.ParseUint(s, 16, 8); err == nil {
+				rec.Flags = uint32(f)
+			}
+		default:
+			rec.Attributes = append(rec.Attributes, otlpKeyValue{Key: key, Value: otlpValue(v)})
+		}
+	}
+	if traceID != "" {
+		rec.TraceID, rec.SpanID = traceID, spanID
+	} else if spanID != "" {
+		rec.Attributes = append(rec.Attributes, otlpKeyValue{Key: SpanIDFieldName, Value: otlpValue(spanID)})
+	}
+	return rec, true
+}
+
+func otlpValue(v any) otlpAnyValue {
+	switch t := v.(type) {
+	case string:
+		return otlpAnyValue{String: &t}
+	case bool:
+		return otlpAnyValue{Bool: &t}
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			s := t.String()
+			return otlpAnyValue{Int: &s}
+		}
+		f, _ := t.Float64()
+		return otlpAnyValue{Double: &f}
+	case []any:
+		arr := &otlpArrayValue{Values: make([]otlpAnyValue, 0, len(t))}
+		for _, e := range t {
+			arr.Values = append(arr.Values, otlpValue(e))
+		}
+		return otlpAnyValue{Array: arr}
+	case map[string]any:
+		kv := &otlpKvList{Values: make([]otlpKeyValue, 0, len(t))}
+		for k, e := range t {
+			kv.Values = append(kv.Values, otlpKeyValue{Key: k, Value: otlpValue(e)})
+		}
+		return otlpAnyValue{KvList: kv}
+	default: // null
+		return otlpAnyValue{}
+	}
+}
+
+// otlpSeverity maps zerolog levels to OTel severity numbers.
+func otlpSeverity(lvl string) (int, string) {
+	switch lvl {
+	case zerolog.LevelTraceValue:
+		return 1, "TRACE"
+	case zerolog.LevelDebugValue:
+		return 5, "DEBUG"
+	case zerolog.LevelInfoValue:
+		return 9, "INFO"
+	case zerolog.LevelWarnValue:
+		return 13, "WARN"
+	case zerolog.LevelErrorValue:
+		return 17, "ERROR"
+	case zerolog.LevelFatalValue:
+		return 21, "FATAL"
+	case zerolog.LevelPanicValue:
+		return 24, "FATAL4"
+	}
+	return 0, ""
+}
+
+// isHexID reports whether s is a valid (non-zero) n-byte hex ID.
+func isHexID(s string, n int) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == n && strings.Trim(s, "0") != ""
+}
@@ -0,0 +1,250 @@
+package logger
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otlpReceiver is a stub OTLP/HTTP logs endpoint. It decodes requests with
+// its own types, following the OTLP/JSON spec rather than the sink's.
+type otlpReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []receivedLogs
+}
+
+type receivedLogs struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []receivedKV `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []receivedRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type receivedRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 json.RawMessage `json:"body"`
+	Attributes           []receivedKV    `json:"attributes"`
+	TraceID              string          `json:"traceId"`
+	SpanID               string          `json:"spanId"`
+	Flags                uint32          `json:"flags"`
+}
+
+type receivedKV struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+func newOTLPReceiver(t *testing.T) *otlpReceiver {
+	rc := &otlpReceiver{}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Method != http.MethodPost {
+			t.Errorf("got %s %s, want POST /v1/logs", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			body = zr
+		}
+		var req receivedLogs
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, req)
+		rc.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *otlpReceiver) records() []receivedRecord {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var out []receivedRecord
+	for _, req := range rc.requests {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				out = append(out, sl.LogRecords...)
+			}
+		}
+	}
+	return out
+}
+
+func attr(kvs []receivedKV, key string) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return string(kv.Value)
+		}
+	}
+	return ""
+}
+
+func TestOTLPSinkExport(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		t.Run("gzip="+strconv.FormatBool(gz), func(t *testing.T) {
+			rc := newOTLPReceiver(t)
+			sink := NewOTLPSink(OTLPSinkConfig{
+				HTTPSinkConfig: HTTPSinkConfig{URL: rc.URL, Gzip: gz},
+				Resource:       map[string]string{"service.name": "orders"},
+			})
+			log := NewWithWriter(sink, Options{Level: "debug"})
+
+			sc := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+				SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+				TraceFlags: trace.FlagsSampled,
+			})
+			ctx := trace.ContextWithSpanContext(context.Background(), sc)
+			before := time.Now().Add(-time.Second)
+			log.Warn(ctx).Str("order", "o-1").Int("items", 3).Bool("paid", true).Msg("slow checkout")
+			log.Error(context.Background()).Msg("no span")
+			if err := log.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			rc.mu.Lock()
+			if len(rc.requests) == 0 || len(rc.requests[0].ResourceLogs) != 1 {
+				rc.mu.Unlock()
+				t.Fatalf("requests = %+v", rc.requests)
+			}
+			rl := rc.requests[0].ResourceLogs[0]
+			rc.mu.Unlock()
+			if got := attr(rl.Resource.Attributes, "service.name"); got != `{"stringValue":"orders"}` {
+				t.Errorf("resource service.name = %s", got)
+			}
+			if got := rl.ScopeLogs[0].Scope.Name; got != otlpScope {
+				t.Errorf("scope = %q", got)
+			}
+
+			recs := rc.records()
+			if len(recs) != 2 {
+				t.Fatalf("got %d records, want 2", len(recs))
+			}
+			warn, plain := recs[0], recs[1]
+			if warn.SeverityNumber != 13 || warn.SeverityText != "WARN" {
+				t.Errorf("severity = %d %q, want 13 WARN", warn.SeverityNumber, warn.SeverityText)
+			}
+			if string(warn.Body) != `{"stringValue":"slow checkout"}` {
+				t.Errorf("body = %s", warn.Body)
+			}
+			if warn.TraceID != sc.TraceID().String() || warn.SpanID != sc.SpanID().String() || warn.Flags != 1 {
+				t.Errorf("trace context = %s/%s/%d, want %s/%s/1", warn.TraceID, warn.SpanID, warn.Flags, sc.TraceID(), sc.SpanID())
+			}
+			for key, want := range map[string]string{
+				"order": `{"stringValue":"o-1"}`,
+				"items": `{"intValue":"3"}`,
+				"paid":  `{"boolValue":true}`,
+			} {
+				if got := attr(warn.Attributes, key); got != want {
+					t.Errorf("attribute %s = %s, want %s", key, got, want)
+				}
+			}
+			for _, key := range []string{"lvl", "msg", "ts", TraceIDFieldName, SpanIDFieldName, TraceFlagsFieldName} {
+				if got := attr(warn.Attributes, key); got != "" {
+					t.Errorf("attribute %s = %s, want it mapped to a record field", key, got)
+				}
+			}
+			ns, err := strconv.ParseInt(warn.TimeUnixNano, 10, 64)
+			if err != nil || time.Unix(0, ns).Before(before) || warn.ObservedTimeUnixNano == "" {
+				t.Errorf("time = %q, observed = %q", warn.TimeUnixNano, warn.ObservedTimeUnixNano)
+			}
+			if plain.SeverityText != "ERROR" || plain.TraceID != "" || plain.SpanID != "" {
+				t.Errorf("record without span = %+v", plain)
+			}
+		})
+	}
+}
+
+func TestOTLPRecord(t *testing.T) {
+	setGlobals()
+	tests := []struct {
+		name      string
+		line      string
+		ok        bool
+		traceID   string
+		spanID    string
+		spanAttr  bool // span ID kept as an attribute
+		severity  int
+		wantAttrs map[string]string
+	}{
+		{name: "not json", line: `plain text`, ok: false},
+		{
+			name: "levels", line: `{"lvl":"debug","msg":"m"}`, ok: true, severity: 5,
+		},
+		{
+			name: "span without trace", line: `{"lvl":"info","span_id":"0102030405060708"}`, ok: true, severity: 9, spanAttr: true,
+		},
+		{
+			name: "invalid trace id kept as attribute", line: `{"lvl":"info","trace_id":"req-123"}`, ok: true, severity: 9,
+			wantAttrs: map[string]string{"trace_id": `{"stringValue":"req-123"}`},
+		},
+		{
+			name: "nested values", line: `{"lvl":"info","n":1.5,"list":[1,"a"],"obj":{"k":null}}`, ok: true, severity: 9,
+			wantAttrs: map[string]string{
+				"n":    `{"doubleValue":1.5}`,
+				"list": `{"arrayValue":{"values":[{"intValue":"1"},{"stringValue":"a"}]}}`,
+				"obj":  `{"kvlistValue":{"values":[{"key":"k","value":{}}]}}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ok := otlpRecord([]byte(tt.line))
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if rec.SeverityNumber != tt.severity {
+				t.Errorf("severity = %d, want %d", rec.SeverityNumber, tt.severity)
+			}
+			if rec.TraceID != tt.traceID || rec.SpanID != tt.spanID {
+				t.Errorf("trace/span = %q/%q", rec.TraceID, rec.SpanID)
+			}
+			b, _ := json.Marshal(rec)
+			var got receivedRecord
+			_ = json.Unmarshal(b, &got)
+			if has := attr(got.Attributes, SpanIDFieldName) != ""; has != tt.spanAttr {
+				t.Errorf("span_id attribute present = %v, want %v", has, tt.spanAttr)
+			}
+			for k, want := range tt.wantAttrs {
+				if v := attr(got.Attributes, k); v != want {
+					t.Errorf("attribute %s = %s, want %s", k, v, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package logger
+
+// raceEnabled reports whether tests run under the race detector, which
+// makes otherwise allocation-free code allocate.
+const raceEnabled = true
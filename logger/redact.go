@@ -0,0 +1,249 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedactFields are masked wherever they appear as a field name, at any
+// nesting depth. Matching ignores case, '_' and '-' and also catches longer
+// names ("access_token", "X-Api-Key", "user_email").
+var DefaultRedactFields = []string{"password", "passwd", "secret", "authorization", "cookie", "token", "api_key", "email"}
+
+// DefaultRedactPatterns are masked inside every string value, message text
+// included: JWTs, bearer credentials and card numbers (Luhn-checked).
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	authPattern,
+	cardPattern,
+}
+
+var (
+	authPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`) // the scheme is kept
+	cardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)                   // masked only if Luhn-valid
+)
+
+const redacted = "****"
+
+// redactor rewrites each JSON log line before it reaches any writer (stdout,
+// console or remote sinks).
+type redactor struct {
+	next     io.Writer
+	fields   []string         // normalized names
+	patterns []*regexp.Regexp // matched in string values
+	inline   *regexp.Regexp   // "password=..." / `"token":"..."` inside strings
+}
+
+func newRedactor(next io.Writer, fields []string, patterns []*regexp.Regexp) *redactor {
+	r := &redactor{next: next, patterns: append(append([]*regexp.Regexp{}, DefaultRedactPatterns...), patterns...)}
+	quoted := make([]string, 0, len(fields)+len(DefaultRedactFields))
+	for _, f := range append(append([]string{}, DefaultRedactFields...), fields...) {
+		if n := normalizeKey(f); n != "" {
+			r.fields = append(r.fields, n)
+			quoted = append(quoted, strings.NewReplacer("_", "[_-]?", "-", "[_-]?").Replace(regexp.QuoteMeta(strings.ToLower(f))))
+		}
+	}
+	r.inline = regexp.MustCompile(`(?i)(\\?"?[\w-]*(?:` + strings.Join(quoted, "|") + `)[\w-]*\\?"?\s*[:=]\s*\\?"?)([^"\\\s&,;}]+)`)
+	return r
+}
+
+func (r *redactor) Write(p []byte) (int, error) {
+	if !r.suspect(p) {
+		return r.next.Write(p)
+	}
+	out, err := r.rewrite(p)
+	if err != nil {
+		// Not a JSON line (custom writer upstream); mask what the patterns find.
+		out = []byte(r.text(string(p)))
+	}
+	if _, err := r.next.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// suspect is a cheap prefilter so clean lines skip the JSON rewrite. It
+// doesn't allocate.
+func (r *redactor) suspect(p []byte) bool {
+	for _, f := range r.fields {
+		if containsName(p, f) {
+			return true
+		}
+	}
+	for _, re := range r.patterns {
+		if re.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) rewrite(p []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	var out bytes.Buffer
+	out.Grow(len(p))
+	if err := r.value(dec, &out); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// value copies one JSON value from dec to out, masking as it goes. Key order
+// is preserved.
+func (r *redactor) value(dec *json.Decoder, out *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		closing := byte('}')
+		if t == '[' {
+			closing = ']'
+		}
+		out.WriteByte(byte(t))
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if t == '{' {
+				kt, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := kt.(string)
+				writeJSONString(out, key)
+				out.WriteByte(':')
+				if r.maskKey(key) {
+					var skip json.RawMessage
+					if err := dec.Decode(&skip); err != nil {
+						return err
+					}
+					writeJSONString(out, redacted)
+					continue
+				}
+			}
+			if err := r.value(dec, out); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		out.WriteByte(closing)
+	case string:
+		writeJSONString(out, r.text(t))
+	case json.Number:
+		out.WriteString(t.String())
+	case bool:
+		if t {
+			out.WriteString("true")
+		} else {
+			out.WriteString("false")
+		}
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+func (r *redactor) maskKey(key string) bool {
+	k := normalizeKey(key)
+	for _, f := range r.fields {
+		if strings.Contains(k, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// text masks patterns and inline key=value secrets in free text.
+func (r *redactor) text(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			switch re {
+			case cardPattern:
+				if !luhn(m) {
+					return m // a long number that isn't a card number
+				}
+			case authPattern:
+				if i := strings.IndexAny(m, " \t"); i > 0 {
+					return m[:i+1] + redacted // keep the auth scheme
+				}
+			}
+			return redacted
+		})
+	}
+	return r.inline.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// containsName reports whether p contains the normalized name f, ignoring
+// ASCII case and any '_' or '-' inside the match, as normalizeKey does.
+func containsName(p []byte, f string) bool {
+	if f == "" {
+		return true
+	}
+	for i := range p {
+		if lowerASCII(p[i]) != f[0] {
+			continue
+		}
+		j := 1
+		for k := i + 1; j < len(f) && k < len(p); k++ {
+			c := p[k]
+			if c == '_' || c == '-' {
+				continue
+			}
+			if lowerASCII(c) != f[j] {
+				break
+			}
+			j++
+		}
+		if j == len(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func normalizeKey(s string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	out.Truncate(out.Len() - 1) // Encode appends '\n'
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SampleRule limits one level: per message and interval, the first First
+// events are kept, then 1 in Thereafter (0 drops the rest).
+type SampleRule struct {
+	First      int
+	Thereafter int
+}
+
+// sampler drops and collapses lines before redaction and the writers. It
+// works on the JSON line, so it sees the final message and fields.
+type sampler struct {
+	next io.Writer
+
+	rules    map[zerolog.Level]SampleRule
+	interval time.Duration
+	window   time.Duration // dedupe window; 0 disables
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int  // level+msg -> events this interval
+	dups   map[string]*dup // error line (sans ts and ids) -> repeats this window
+}
+
+type dup struct {
+	line []byte
+	n    int
+}
+
+func newSampler(next io.Writer, rules map[zerolog.Level]SampleRule, interval, window time.Duration) *sampler {
+	return &sampler{
+		next:     next,
+		rules:    rules,
+		interval: firstNonZero(interval, time.Second),
+		window:   window,
+		counts:   map[string]int{},
+		dups:     map[string]*dup{},
+	}
+}
+
+func (s *sampler) Write(p []byte) (int, error) {
+	lvl, msg := lineLevelMsg(p)
+	if rule, ok := s.rules[lvl]; ok && !s.keep(lvl.String()+"\x00"+msg, rule) {
+		return len(p), nil
+	}
+	if s.window > 0 && lvl >= zerolog.ErrorLevel && lvl != zerolog.NoLevel && !s.first(p) {
+		return len(p), nil
+	}
+	return s.next.Write(p)
+}
+
+func (s *sampler) keep(key string, rule SampleRule) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.start) >= s.interval {
+		clear(s.counts)
+		s.start = now
+	}
+	n := s.counts[key] + 1
+	s.counts[key] = n
+	if n <= rule.First {
+		return true
+	}
+	return rule.Thereafter > 0 && (n-rule.First)%rule.Thereafter == 0
+}
+
+// first reports whether p is the first of its kind in the dedupe window.
+// Repeats are counted, and once the window closes a single line carrying
+// "repeated":N is written for them.
+func (s *sampler) first(p []byte) bool {
+	// Request-scoped fields differ between otherwise identical errors.
+	key := p
+	for _, f := range []string{zerolog.TimestampFieldName, TraceIDFieldName, SpanIDFieldName, TraceFlagsFieldName, TenantIDFieldName, UserIDFieldName, RequestIDFieldName} {
+		key = withoutField(key, f)
+	}
+	k := string(key) // p is reused by zerolog after Write returns
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.dups[k]; ok {
+		d.n++
+		return false
+	}
+	s.dups[k] = &dup{line: append([]byte(nil), p...)}
+	time.AfterFunc(s.window, func() {
+		s.mu.Lock()
+		d := s.dups[k]
+		delete(s.dups, k)
+		s.mu.Unlock()
+		if d != nil && d.n > 0 {
+			_, _ = s.next.Write(withField(d.line, "repeated", strconv.Itoa(d.n)))
+		}
+	})
+	return true
+}
+
+// lineLevelMsg reads the level and message of a JSON log line.
+func lineLevelMsg(p []byte) (zerolog.Level, string) {
+	lvl, msg := zerolog.NoLevel, ""
+	dec := json.NewDecoder(bytes.NewReader(p))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return lvl, msg
+	}
+	for dec.More() {
+		kt, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, _ := kt.(string)
+		if key != zerolog.LevelFieldName && key != zerolog.MessageFieldName {
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				break
+			}
+			continue
+		}
+		var v string
+		if dec.Decode(&v) != nil {
+			break
+		}
+		if key == zerolog.MessageFieldName {
+			msg = v
+		} else if l, err := zerolog.ParseLevel(v); err == nil {
+			lvl = l
+		}
+	}
+	return lvl, msg
+}
+
+// withoutField drops a top-level string field such as "ts":"..." from a line.
+func withoutField(p []byte, name string) []byte {
+	key := []byte(`"` + name + `":"`)
+	i := bytes.Index(p, key)
+	if i < 0 {
+		return p
+	}
+	j := bytes.IndexByte(p[i+len(key):], '"')
+	if j < 0 {
+		return p
+	}
+	end := i + len(key) + j + 1
+	if end < len(p) && p[end] == ',' {
+		end++
+	}
+	return append(append([]byte(nil), p[:i]...), p[end:]...)
+}
+
+// withField appends "name":raw to a JSON object line.
+func withField(p []byte, name, raw string) []byte {
+	end := bytes.LastIndexByte(p, '}')
+	if end < 0 {
+		return p
+	}
+	out := append([]byte(nil), p[:end]...)
+	if len(bytes.TrimSpace(out)) > 1 {
+		out = append(out, ',')
+	}
+	out = append(out, `"`+name+`":`+raw+`}`...)
+	return append(out, p[end+1:]...)
+}
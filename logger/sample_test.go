@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// lineBuffer collects written lines; the dedupe timer writes from its own
+// goroutine.
+type lineBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (b *lineBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, string(bytes.TrimSpace(p)))
+	return len(p), nil
+}
+
+func (b *lineBuffer) get() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.lines...)
+}
+
+func jsonLine(level zerolog.Level, msg string, fields ...string) []byte {
+	extra := ""
+	for i := 0; i+1 < len(fields); i += 2 {
+		extra += fmt.Sprintf(`,%q:%q`, fields[i], fields[i+1])
+	}
+	return fmt.Appendf(nil, `{%q:%q%s,%q:%q}`+"\n", zerolog.LevelFieldName, level.String(), extra, zerolog.MessageFieldName, msg)
+}
+
+func TestSamplerSampling(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules map[zerolog.Level]SampleRule
+		level zerolog.Level
+		msgs  []string
+		want  int
+	}{
+		{name: "no rule keeps all", level: zerolog.InfoLevel, msgs: repeat("a", 10), want: 10},
+		{name: "rule for another level", rules: map[zerolog.Level]SampleRule{zerolog.DebugLevel: {First: 1}}, level: zerolog.InfoLevel, msgs: repeat("a", 10), want: 10},
+		{name: "first only", rules: map[zerolog.Level]SampleRule{zerolog.InfoLevel: {First: 2}}, level: zerolog.InfoLevel, msgs: repeat("a", 10), want: 2},
+		{name: "first then every third", rules: map[zerolog.Level]SampleRule{zerolog.InfoLevel: {First: 2, Thereafter: 3}}, level: zerolog.InfoLevel, msgs: repeat("a", 10), want: 4}, // 1, 2, 5, 8
+		{name: "counted per message", rules: map[zerolog.Level]SampleRule{zerolog.InfoLevel: {First: 1}}, level: zerolog.InfoLevel, msgs: append(repeat("a", 3), repeat("b", 3)...), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lineBuffer
+			s := newSampler(&out, tt.rules, time.Hour, 0)
+			for _, m := range tt.msgs {
+				_, _ = s.Write(jsonLine(tt.level, m))
+			}
+			if got := len(out.get()); got != tt.want {
+				t.Errorf("kept %d lines, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplerIntervalResets(t *testing.T) {
+	var out lineBuffer
+	s := newSampler(&out, map[zerolog.Level]SampleRule{zerolog.InfoLevel: {First: 1}}, 20*time.Millisecond, 0)
+	_, _ = s.Write(jsonLine(zerolog.InfoLevel, "a"))
+	_, _ = s.Write(jsonLine(zerolog.InfoLevel, "a"))
+	time.Sleep(30 * time.Millisecond)
+	_, _ = s.Write(jsonLine(zerolog.InfoLevel, "a"))
+	if got := len(out.get()); got != 2 {
+		t.Errorf("kept %d lines, want 2", got)
+	}
+}
+
+func TestSamplerDedupe(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      [][]byte
+		wantFirst  int    // lines written right away
+		wantRepeat string // `"repeated":N` expected after the window, "" for none
+	}{
+		{
+			name: "identical errors",
+			lines: [][]byte{
+				jsonLine(zerolog.ErrorLevel, "db down"),
+				jsonLine(zerolog.ErrorLevel, "db down"),
+				jsonLine(zerolog.ErrorLevel, "db down"),
+			},
+			wantFirst:  1,
+			wantRepeat: `"repeated":2`,
+		},
+		{
+			name: "request-scoped fields ignored",
+			lines: [][]byte{
+				jsonLine(zerolog.ErrorLevel, "db down", zerolog.TimestampFieldName, "t1", TraceIDFieldName, "a", RequestIDFieldName, "r1"),
+				jsonLine(zerolog.ErrorLevel, "db down", zerolog.TimestampFieldName, "t2", TraceIDFieldName, "b", RequestIDFieldName, "r2"),
+				jsonLine(zerolog.ErrorLevel, "db down", zerolog.TimestampFieldName, "t3", SpanIDFieldName, "c", TenantIDFieldName, "x", UserIDFieldName, "u"),
+			},
+			wantFirst:  1,
+			wantRepeat: `"repeated":2`,
+		},
+		{
+			name: "other fields differ",
+			lines: [][]byte{
+				jsonLine(zerolog.ErrorLevel, "db down", "host", "a"),
+				jsonLine(zerolog.ErrorLevel, "db down", "host", "b"),
+			},
+			wantFirst: 2,
+		},
+		{
+			name: "below error not deduped",
+			lines: [][]byte{
+				jsonLine(zerolog.WarnLevel, "slow"),
+				jsonLine(zerolog.WarnLevel, "slow"),
+			},
+			wantFirst: 2,
+		},
+		{
+			name:      "single error has no repeat line",
+			lines:     [][]byte{jsonLine(zerolog.ErrorLevel, "once")},
+			wantFirst: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lineBuffer
+			s := newSampler(&out, nil, 0, 20*time.Millisecond)
+			for _, l := range tt.lines {
+				_, _ = s.Write(l)
+			}
+			if got := len(out.get()); got != tt.wantFirst {
+				t.Fatalf("wrote %d lines, want %d", got, tt.wantFirst)
+			}
+			time.Sleep(60 * time.Millisecond)
+			got := out.get()[tt.wantFirst:]
+			switch {
+			case tt.wantRepeat == "" && len(got) != 0:
+				t.Errorf("unexpected lines after the window: %v", got)
+			case tt.wantRepeat != "" && (len(got) != 1 || !strings.Contains(got[0], tt.wantRepeat)):
+				t.Errorf("after the window got %v, want one line with %s", got, tt.wantRepeat)
+			}
+		})
+	}
+}
+
+func TestWithoutField(t *testing.T) {
+	tests := []struct {
+		in, field, want string
+	}{
+		{in: `{"ts":"x","msg":"m"}`, field: "ts", want: `{"msg":"m"}`},
+		{in: `{"msg":"m"}`, field: "ts", want: `{"msg":"m"}`},
+		{in: `{"ts":1,"msg":"m"}`, field: "ts", want: `{"ts":1,"msg":"m"}`}, // only string values
+	}
+	for _, tt := range tests {
+		if got := string(withoutField([]byte(tt.in), tt.field)); got != tt.want {
+			t.Errorf("withoutField(%s, %q) = %s, want %s", tt.in, tt.field, got, tt.want)
+		}
+	}
+}
+
+func repeat(s string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = s
+	}
+	return out
+}
@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/grpclog"
+)
+
+// ---------- slog -> Loggerx ----------
+
+// SlogHandler returns a slog.Handler that writes through x, so libraries
+// using log/slog (pgx, otel, ...) share its format, levels, redaction and
+// sinks. trace_id/tenant_id/user_id are taken from the ctx passed to
+// InfoContext and friends.
+//
+//	slog.SetDefault(slog.New(logger.SlogHandler(log.Named("pgx"))))
+func SlogHandler(x *Loggerx) slog.Handler {
+	return &slogHandler{x: x, pre: make([][]slog.Attr, 1)}
+}
+
+type slogHandler struct {
+	x      *Loggerx
+	groups []string
+	pre    [][]slog.Attr // WithAttrs, by group depth (len(groups)+1 entries)
+}
+
+func (h *slogHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return h.x.Enabled(zerologLevel(l))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := h.x.event(ctx, zerologLevel(r.Level), -1)
+	if e == nil {
+		return nil
+	}
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		e.Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(f.PC, f.File, f.Line))
+	}
+
+	// Nest record attrs under the open groups, innermost first.
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	n := len(h.groups)
+	inner := append(append([]slog.Attr{}, h.pre[n]...), attrs...)
+	for i := n - 1; i >= 0; i-- {
+		outer := append([]slog.Attr{}, h.pre[i]...)
+		if len(inner) > 0 {
+			outer = append(outer, slog.Attr{Key: h.groups[i], Value: slog.GroupValue(inner...)})
+		}
+		inner = outer
+	}
+	for _, a := range inner {
+		appendAttr(e, a)
+	}
+	e.Msg(r.Message)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := h.clone()
+	n := len(c.groups)
+	c.pre[n] = append(append([]slog.Attr{}, c.pre[n]...), attrs...)
+	return c
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := h.clone()
+	c.groups = append(c.groups, name)
+	c.pre = append(c.pre, nil)
+	return c
+}
+
+func (h *slogHandler) clone() *slogHandler {
+	return &slogHandler{
+		x:      h.x,
+		groups: append([]string{}, h.groups...),
+		pre:    append([][]slog.Attr{}, h.pre...),
+	}
+}
+
+func appendAttr(e *zerolog.Event, a slog.Attr) {
+	v := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key == "" { // inline group
+			for _, ga := range attrs {
+				appendAttr(e, ga)
+			}
+			return
+		}
+		d := zerolog.Dict()
+		for _, ga := range attrs {
+			appendAttr(d, ga)
+		}
+		e.Dict(a.Key, d)
+	case slog.KindString:
+		e.Str(a.Key, v.String())
+	case slog.KindInt64:
+		e.Int64(a.Key, v.Int64())
+	case slog.KindUint64:
+		e.Uint64(a.Key, v.Uint64())
+	case slog.KindFloat64:
+		e.Float64(a.Key, v.Float64())
+	case slog.KindBool:
+		e.Bool(a.Key, v.Bool())
+	case slog.KindDuration:
+		e.Dur(a.Key, v.Duration())
+	case slog.KindTime:
+		e.Time(a.Key, v.Time())
+	default:
+		if err, ok := v.Any().(error); ok {
+			e.AnErr(a.Key, err)
+		} else {
+			e.Interface(a.Key, v.Any())
+		}
+	}
+}
+
+func zerologLevel(l slog.Level) zerolog.Level {
+	switch {
+	case l < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case l < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case l < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case l < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// ---------- Loggerx -> slog ----------
+
+// FromSlog returns a Loggerx that hands every event to h, for apps whose
+// logging is already built on log/slog. Context fields (trace_id, ...) and
+// "component" arrive as attributes. Only the level and redaction settings of
+// opts apply.
+func FromSlog(h slog.Handler, opts Options) *Loggerx {
+	setGlobals()
+	return newLoggerx(slogWriter{h: h}, opts, optLevels(opts))
+}
+
+type slogWriter struct{ h slog.Handler }
+
+func (w slogWriter) Write(p []byte) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, fmt.Errorf("logger: slog writer: not a JSON object")
+	}
+	lvl, msg := slog.LevelInfo, ""
+	var attrs []slog.Attr
+	for dec.More() {
+		kt, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+		key, _ := kt.(string)
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return 0, err
+		}
+		switch key {
+		case zerolog.LevelFieldName:
+			s, _ := v.(string)
+			lvl = slogLevel(s)
+		case zerolog.MessageFieldName:
+			msg, _ = v.(string)
+		case zerolog.TimestampFieldName:
+			// The record gets its own, more precise timestamp.
+		default:
+			attrs = append(attrs, slog.Any(key, jsonValue(v)))
+		}
+	}
+	ctx := context.Background()
+	if !w.h.Enabled(ctx, lvl) {
+		return len(p), nil
+	}
+	r := slog.NewRecord(time.Now(), lvl, msg, 0)
+	r.AddAttrs(attrs...)
+	return len(p), w.h.Handle(ctx, r)
+}
+
+func slogLevel(s string) slog.Level {
+	switch l, _ := zerolog.ParseLevel(s); l {
+	case zerolog.TraceLevel:
+		return slog.LevelDebug - 4
+	case zerolog.DebugLevel:
+		return slog.LevelDebug
+	case zerolog.WarnLevel:
+		return slog.LevelWarn
+	case zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// jsonValue turns json.Number into int64/float64 so handlers print numbers.
+func jsonValue(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// ---------- stdlib log and grpclog ----------
+
+// StdLogger returns a *log.Logger that writes each line as one event at lvl,
+// e.g. for http.Server.ErrorLog.
+func StdLogger(x *Loggerx, lvl zerolog.Level) *log.Logger {
+	return log.New(stdWriter{x: x, lvl: lvl}, "", 0)
+}
+
+type stdWriter struct {
+	x   *Loggerx
+	lvl zerolog.Level
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	// Frames above Write: log.(*Logger).output, Printf/Println/..., callsite.
+	w.x.event(context.Background(), w.lvl, 3).Msg(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// GRPCLogger adapts x to grpclog, so gRPC internals log through it:
+//
+//	grpclog.SetLoggerV2(logger.GRPCLogger(log.Named("grpc")))
+//
+// gRPC's info output is chatty; quiet it with ComponentLevels {"grpc": "warn"}.
+func GRPCLogger(x *Loggerx) grpclog.DepthLoggerV2 { return grpcLogger{x} }
+
+type grpcLogger struct{ x *Loggerx }
+
+var _ grpclog.DepthLoggerV2 = grpcLogger{}
+
+func (g grpcLogger) log(lvl zerolog.Level, depth int, msg string) {
+	// Frames above log: the grpcLogger method, then depth more to the callsite.
+	g.x.event(context.Background(), lvl, 2+depth).Msg(msg)
+	if lvl == zerolog.FatalLevel {
+		_ = g.x.Flush(context.Background())
+		os.Exit(1)
+	}
+}
+
+func (g grpcLogger) Info(args ...any)    { g.log(zerolog.InfoLevel, 0, fmt.Sprint(args...)) }
+func (g grpcLogger) Infoln(args ...any)  { g.log(zerolog.InfoLevel, 0, sprintln(args)) }
+func (g grpcLogger) Warning(args ...any) { g.log(zerolog.WarnLevel, 0, fmt.Sprint(args...)) }
+func (g grpcLogger) Error(args ...any)   { g.log(zerolog.ErrorLevel, 0, fmt.Sprint(args...)) }
+func (g grpcLogger) Fatal(args ...any)   { g.log(zerolog.FatalLevel, 0, fmt.Sprint(args...)) }
+
+func (g grpcLogger) Warningln(args ...any) { g.log(zerolog.WarnLevel, 0, sprintln(args)) }
+func (g grpcLogger) Errorln(args ...any)   { g.log(zerolog.ErrorLevel, 0, sprintln(args)) }
+func (g grpcLogger) Fatalln(args ...any)   { g.log(zerolog.FatalLevel, 0, sprintln(args)) }
+
+func (g grpcLogger) Infof(format string, args ...any) {
+	g.log(zerolog.InfoLevel, 0, fmt.Sprintf(format, args...))
+}
+func (g grpcLogger) Warningf(format string, args ...any) {
+	g.log(zerolog.WarnLevel, 0, fmt.Sprintf(format, args...))
+}
+func (g grpcLogger) Errorf(format string, args ...any) {
+	g.log(zerolog.ErrorLevel, 0, fmt.Sprintf(format, args...))
+}
+func (g grpcLogger) Fatalf(format string, args ...any) {
+	g.log(zerolog.FatalLevel, 0, fmt.Sprintf(format, args...))
+}
+
+func (g grpcLogger) InfoDepth(depth int, args ...any) {
+	g.log(zerolog.InfoLevel, depth, sprintln(args))
+}
+func (g grpcLogger) WarningDepth(depth int, args ...any) {
+	g.log(zerolog.WarnLevel, depth, sprintln(args))
+}
+func (g grpcLogger) ErrorDepth(depth int, args ...any) {
+	g.log(zerolog.ErrorLevel, depth, sprintln(args))
+}
+func (g grpcLogger) FatalDepth(depth int, args ...any) {
+	g.log(zerolog.FatalLevel, depth, sprintln(args))
+}
+
+// V reports whether verbosity level l is enabled: 0 always, higher levels
+// only when the logger is at debug.
+func (g grpcLogger) V(l int) bool { return l <= 0 || g.x.Enabled(zerolog.DebugLevel) }
+
+func sprintln(args []any) string { return strings.TrimSuffix(fmt.Sprintln(args...), "\n") }
@@ -0,0 +1,241 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// decodeLine parses one JSON log line.
+func decodeLine(t *testing.T, line string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		t.Fatalf("not JSON: %q: %v", line, err)
+	}
+	return m
+}
+
+func TestSlogHandlerConformance(t *testing.T) {
+	var out *lineBuffer
+	slogtest.Run(t, func(*testing.T) slog.Handler {
+		out = &lineBuffer{}
+		return SlogHandler(NewWithWriter(out, Options{Level: "debug", DisableRedaction: true}))
+	}, func(t *testing.T) map[string]any {
+		if t.Name() == "TestSlogHandlerConformance/zero-time" {
+			t.Skip("every event gets its own timestamp")
+		}
+		lines := out.get()
+		if len(lines) != 1 {
+			t.Fatalf("got %d lines, want 1", len(lines))
+		}
+		m := decodeLine(t, lines[0])
+		// slogtest expects slog's built-in keys.
+		m[slog.TimeKey], m[slog.LevelKey] = m[zerolog.TimestampFieldName], m[zerolog.LevelFieldName]
+		delete(m, zerolog.TimestampFieldName)
+		delete(m, zerolog.LevelFieldName)
+		delete(m, zerolog.CallerFieldName)
+		return m
+	})
+}
+
+func TestSlogHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(ctx context.Context, l *slog.Logger)
+		want map[string]any // fields that must be present; nil for no line
+	}{
+		{
+			name: "types",
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.InfoContext(ctx, "m", "s", "x", "i", -1, "u", uint64(2), "f", 1.5, "b", true,
+					"d", 1500*time.Millisecond, "err", errors.New("boom"), "any", []int{1})
+			},
+			want: map[string]any{"lvl": "info", "msg": "m", "s": "x", "i": -1.0, "u": 2.0, "f": 1.5, "b": true, "d": 1500.0, "err": "boom", "any": []any{1.0}},
+		},
+		{
+			name: "levels",
+			log:  func(ctx context.Context, l *slog.Logger) { l.Log(ctx, slog.LevelWarn+2, "m") },
+			want: map[string]any{"lvl": "warn"},
+		},
+		{
+			name: "below level",
+			log:  func(ctx context.Context, l *slog.Logger) { l.DebugContext(ctx, "m") },
+		},
+		{
+			name: "groups",
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.With("a", 1).WithGroup("g").With("b", 2).WithGroup("h").InfoContext(ctx, "m", "c", 3)
+			},
+			want: map[string]any{"a": 1.0, "g": map[string]any{"b": 2.0, "h": map[string]any{"c": 3.0}}},
+		},
+		{
+			name: "context fields and component",
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.InfoContext(WithFields(WithTenantID(WithTraceID(ctx, "t1"), "acme"), "order_id", "o-1"), "m")
+			},
+			want: map[string]any{"trace_id": "t1", "tenant_id": "acme", "order_id": "o-1", "component": "pgx"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lineBuffer
+			x := NewWithWriter(&out, Options{Level: "info"})
+			tt.log(context.Background(), slog.New(SlogHandler(x.Named("pgx"))))
+			lines := out.get()
+			if tt.want == nil {
+				if len(lines) != 0 {
+					t.Errorf("wrote %q, want nothing", lines)
+				}
+				return
+			}
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, want 1", len(lines))
+			}
+			got := decodeLine(t, lines[0])
+			for k, want := range tt.want {
+				if g, _ := json.Marshal(got[k]); string(g) != mustJSON(t, want) {
+					t.Errorf("%s = %s, want %s", k, g, mustJSON(t, want))
+				}
+			}
+			if c, _ := got["caller"].(string); !strings.HasPrefix(c, "logger/slog_test.go:") {
+				t.Errorf("caller = %q, want the slog callsite", c)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFromSlog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(ctx context.Context, x *Loggerx)
+		want []string // JSON fields expected in the slog output; nil for none
+	}{
+		{name: "info", log: func(ctx context.Context, x *Loggerx) { x.Info(ctx).Int("n", 3).Float64("r", 0.5).Msg("hi") }, want: []string{`"level":"INFO","msg":"hi"`, `"n":3`, `"r":0.5`}},
+		{name: "warn", log: func(ctx context.Context, x *Loggerx) { x.Warn(ctx).Msg("w") }, want: []string{`"level":"WARN"`}},
+		{name: "error", log: func(ctx context.Context, x *Loggerx) { x.Error(ctx).Msg("e") }, want: []string{`"level":"ERROR"`}},
+		{name: "debug filtered by the logger", log: func(ctx context.Context, x *Loggerx) { x.Debug(ctx).Msg("d") }},
+		{name: "debug filtered by the handler", log: func(ctx context.Context, x *Loggerx) {
+			x.SetLevel("", zerolog.DebugLevel, 0)
+			x.Debug(ctx).Msg("d")
+		}},
+		{name: "context and component", log: func(ctx context.Context, x *Loggerx) {
+			x.Named("db").Info(WithUserID(WithTraceID(ctx, "t1"), "u1")).Msg("q")
+		}, want: []string{`"trace_id":"t1"`, `"user_id":"u1"`, `"component":"db"`}},
+		{name: "redacted", log: func(ctx context.Context, x *Loggerx) { x.Info(ctx).Str("password", "hunter2").Msg("login") }, want: []string{`"password":"****"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo, ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			}})
+			tt.log(context.Background(), FromSlog(h, Options{Level: "info"}))
+			got := buf.String()
+			if tt.want == nil {
+				if got != "" {
+					t.Errorf("wrote %q, want nothing", got)
+				}
+				return
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("wrote %q, want it to contain %s", got, want)
+				}
+			}
+			if strings.Contains(got, `"ts"`) || strings.Contains(got, `"lvl"`) {
+				t.Errorf("wrote %q; zerolog's own ts and lvl must not leak", got)
+			}
+		})
+	}
+}
+
+func TestSlogWriterRejectsNonJSON(t *testing.T) {
+	w := slogWriter{h: slog.NewTextHandler(&bytes.Buffer{}, nil)}
+	if _, err := w.Write([]byte("plain text\n")); err == nil {
+		t.Error("Write() = nil error for a non-JSON line")
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var out lineBuffer
+	x := NewWithWriter(&out, Options{Level: "info"})
+	StdLogger(x.Named("http"), zerolog.ErrorLevel).Printf("http: TLS handshake error from %s", "10.0.0.1:1234")
+
+	lines := out.get()
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	got := decodeLine(t, lines[0])
+	if got["lvl"] != "error" || got["msg"] != "http: TLS handshake error from 10.0.0.1:1234" || got["component"] != "http" {
+		t.Errorf("line = %v", got)
+	}
+	if c, _ := got["caller"].(string); !strings.HasPrefix(c, "logger/slog_test.go:") {
+		t.Errorf("caller = %q, want the Printf callsite", c)
+	}
+}
+
+func TestGRPCLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(l grpcLogger)
+		wantLvl string
+		wantMsg string
+	}{
+		{name: "Info", call: func(l grpcLogger) { l.Info("a", "b") }, wantLvl: "info", wantMsg: "ab"},
+		{name: "Infoln", call: func(l grpcLogger) { l.Infoln("a", "b") }, wantLvl: "info", wantMsg: "a b"},
+		{name: "Warningf", call: func(l grpcLogger) { l.Warningf("n=%d", 1) }, wantLvl: "warn", wantMsg: "n=1"},
+		{name: "Errorln", call: func(l grpcLogger) { l.Errorln("x", 1) }, wantLvl: "error", wantMsg: "x 1"},
+		{name: "ErrorDepth", call: func(l grpcLogger) { l.ErrorDepth(0, "x", 1) }, wantLvl: "error", wantMsg: "x 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lineBuffer
+			x := NewWithWriter(&out, Options{Level: "info"})
+			tt.call(GRPCLogger(x).(grpcLogger))
+			lines := out.get()
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, want 1", len(lines))
+			}
+			got := decodeLine(t, lines[0])
+			if got["lvl"] != tt.wantLvl || got["msg"] != tt.wantMsg {
+				t.Errorf("line = %v, want %s %q", got, tt.wantLvl, tt.wantMsg)
+			}
+			if c, _ := got["caller"].(string); !strings.HasPrefix(c, "logger/slog_test.go:") {
+				t.Errorf("caller = %q, want the callsite", c)
+			}
+		})
+	}
+}
+
+func TestGRPCLoggerV(t *testing.T) {
+	x := NewWithWriter(&lineBuffer{}, Options{Level: "info"})
+	g := GRPCLogger(x)
+	if !g.V(0) || g.V(2) {
+		t.Errorf("at info: V(0) = %v, V(2) = %v; want true, false", g.V(0), g.V(2))
+	}
+	x.SetLevel("", zerolog.DebugLevel, 0)
+	if !g.V(2) {
+		t.Error("at debug: V(2) = false")
+	}
+}
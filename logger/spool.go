@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpoolConfig enables a disk-backed spool for a remote sink. Batches that
+// can't be delivered are appended to segment files under Dir and replayed in
+// order once the endpoint recovers; segments left by a previous process are
+// replayed on start. Delivery is at-least-once: a segment interrupted by a
+// restart is sent again from its beginning.
+type SpoolConfig struct {
+	Dir          string        // "" disables the spool
+	SegmentBytes int64         // start a new segment above this size (default 8 MiB)
+	MaxBytes     int64         // evict oldest segments above this total (default 256 MiB)
+	MaxAge       time.Duration // evict segments older than this (default 24h)
+}
+
+const spoolExt = ".ndjson"
+
+// spool is a FIFO of NDJSON segment files. It is only used from the sink's
+// loop goroutine and needs no locking.
+type spool struct {
+	cfg SpoolConfig
+
+	segs   []string // oldest first; the last one may be active
+	active *os.File // open for append; nil until the next write
+	size   int64    // size of the active segment
+	next   uint64   // sequence number of the next segment
+	off    int      // bytes of segs[0] already delivered
+}
+
+func openSpool(cfg SpoolConfig) (*spool, error) {
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = 8 << 20
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 256 << 20
+	}
+	cfg.MaxBytes = max(cfg.MaxBytes, cfg.SegmentBytes)
+	cfg.MaxAge = firstNonZero(cfg.MaxAge, 24*time.Hour)
+
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("logger: spool: %w", err)
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("logger: spool: %w", err)
+	}
+	sp := &spool{cfg: cfg}
+	for _, e := range entries {
+		name := e.Name()
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolExt), 10, 64)
+		if e.IsDir() || !strings.HasSuffix(name, spoolExt) || err != nil {
+			continue
+		}
+		sp.segs = append(sp.segs, filepath.Join(cfg.Dir, name))
+		sp.next = max(sp.next, seq+1)
+	}
+	sort.Strings(sp.segs) // zero-padded names sort by sequence
+	return sp, nil
+}
+
+func (sp *spool) empty() bool { return len(sp.segs) == 0 }
+
+// append writes NDJSON data to the active segment, starting a new one when it
+// is full, and returns the number of lines evicted to respect MaxBytes.
+func (sp *spool) append(data []byte) (evicted int, err error) {
+	if sp.active != nil && sp.size >= sp.cfg.SegmentBytes {
+		sp.seal()
+	}
+	if sp.active == nil {
+		path := filepath.Join(sp.cfg.Dir, fmt.Sprintf("%020d%s", sp.next, spoolExt))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return 0, fmt.Errorf("logger: spool: %w", err)
+		}
+		sp.next++
+		sp.active, sp.size = f, 0
+		sp.segs = append(sp.segs, path)
+	}
+	n, err := sp.active.Write(data)
+	sp.size += int64(n)
+	if err == nil {
+		err = sp.active.Sync()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("logger: spool: %w", err)
+	}
+	return sp.evict(time.Now()), nil
+}
+
+// seal closes the active segment so it can be replayed; later writes go to a
+// new one.
+func (sp *spool) seal() {
+	if sp.active != nil {
+		sp.active.Close()
+		sp.active = nil
+	}
+}
+
+// evict removes the oldest segments while the spool is over MaxBytes or they
+// are older than MaxAge, and returns the number of lines lost.
+func (sp *spool) evict(now time.Time) (lines int) {
+	var total int64
+	sizes := make([]int64, len(sp.segs))
+	mtimes := make([]time.Time, len(sp.segs))
+	for i, path := range sp.segs {
+		if st, err := os.Stat(path); err == nil {
+			sizes[i], mtimes[i] = st.Size(), st.ModTime()
+			total += st.Size()
+		}
+	}
+	for i := 0; len(sp.segs) > 0; i++ {
+		last := len(sp.segs) == 1 && sp.active != nil
+		if last || (total <= sp.cfg.MaxBytes && now.Sub(mtimes[i]) <= sp.cfg.MaxAge) {
+			break
+		}
+		path := sp.segs[0]
+		if data, err := os.ReadFile(path); err == nil {
+			lines += bytes.Count(data[min(sp.off, len(data)):], []byte{'\n'})
+		}
+		sp.drop()
+		total -= sizes[i]
+	}
+	return lines
+}
+
+// head returns the undelivered part of the oldest segment, sealing it first
+// if it is still being written.
+func (sp *spool) head() ([]byte, error) {
+	if len(sp.segs) == 1 {
+		sp.seal()
+	}
+	data, err := os.ReadFile(sp.segs[0])
+	if err != nil {
+		return nil, fmt.Errorf("logger: spool: %w", err)
+	}
+	return data[min(sp.off, len(data)):], nil
+}
+
+// ack marks n more bytes of the oldest segment as delivered and removes the
+// segment once all of it is.
+func (sp *spool) ack(n int, done bool) {
+	sp.off += n
+	if done {
+		sp.drop()
+	}
+}
+
+func (sp *spool) drop() {
+	if len(sp.segs) == 1 {
+		sp.seal()
+	}
+	_ = os.Remove(sp.segs[0])
+	sp.segs, sp.off = sp.segs[1:], 0
+}
+
+func (sp *spool) close() { sp.seal() }
@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// collector is an NDJSON endpoint that answers with status and records the
+// lines of accepted requests.
+type collector struct {
+	*httptest.Server
+	status atomic.Int32
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{}
+	c.status.Store(http.StatusOK)
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := int(c.status.Load())
+		if status == http.StatusOK {
+			sc := bufio.NewScanner(r.Body)
+			c.mu.Lock()
+			for sc.Scan() {
+				c.lines = append(c.lines, sc.Text())
+			}
+			c.mu.Unlock()
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *collector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+func spoolSink(url, dir string, spool SpoolConfig) *HTTPSink {
+	spool.Dir = dir
+	return NewHTTPSink(HTTPSinkConfig{
+		URL:          url,
+		BatchSize:    2,
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+		MaxBackoff:   time.Hour, // replay only on Flush
+		Spool:        spool,
+	})
+}
+
+func writeLines(t *testing.T, s *HTTPSink, from, to int) []string {
+	t.Helper()
+	var want []string
+	for i := from; i < to; i++ {
+		line := fmt.Sprintf(`{"n":%d}`, i)
+		want = append(want, line)
+		if _, err := s.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return want
+}
+
+func TestHTTPSinkSpoolReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		outage      int // status while the endpoint is down
+		wantSpooled bool
+	}{
+		{name: "server error", outage: http.StatusServiceUnavailable, wantSpooled: true},
+		{name: "throttled", outage: http.StatusTooManyRequests, wantSpooled: true},
+		{name: "rejected", outage: http.StatusBadRequest, wantSpooled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := newCollector(t)
+			s := spoolSink(c.URL, t.TempDir(), SpoolConfig{})
+			defer s.Close(ctx)
+
+			c.status.Store(int32(tt.outage))
+			down := writeLines(t, s, 0, 5)
+			_ = s.Flush(ctx)
+			c.status.Store(http.StatusOK)
+			up := writeLines(t, s, 5, 8)
+			if err := s.Flush(ctx); err != nil {
+				t.Fatalf("Flush() = %v", err)
+			}
+
+			st := s.Stats()
+			want := up
+			if tt.wantSpooled {
+				// Newer lines queue behind the spooled ones, so all are replayed
+				// in order.
+				want = append(down, up...)
+				if st.Spooled != 8 || st.Sent != 8 || st.Failed != 0 {
+					t.Errorf("Stats() = %+v, want 8 spooled and sent", st)
+				}
+			} else if st.Spooled != 0 || st.Failed != 5 || st.Sent != 3 {
+				t.Errorf("Stats() = %+v, want 5 failed, 3 sent", st)
+			}
+			if got := c.received(); !slices.Equal(got, want) {
+				t.Errorf("received %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHTTPSinkSpoolSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	c := newCollector(t)
+	dir := t.TempDir()
+
+	c.status.Store(http.StatusServiceUnavailable)
+	s := spoolSink(c.URL, dir, SpoolConfig{})
+	want := writeLines(t, s, 0, 3)
+	_ = s.Close(ctx)
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*"+spoolExt)); len(segs) == 0 {
+		t.Fatal("nothing spooled")
+	}
+
+	c.status.Store(http.StatusOK)
+	s = spoolSink(c.URL, dir, SpoolConfig{})
+	defer s.Close(ctx)
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.received(); !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*"+spoolExt)); len(segs) != 0 {
+		t.Errorf("segments left after replay: %v", segs)
+	}
+}
+
+func TestHTTPSinkSpoolEviction(t *testing.T) {
+	ctx := context.Background()
+	c := newCollector(t)
+	c.status.Store(http.StatusServiceUnavailable)
+	// Each batch of two lines is 16 bytes; keep at most two segments.
+	s := spoolSink(c.URL, t.TempDir(), SpoolConfig{SegmentBytes: 16, MaxBytes: 32})
+	defer s.Close(ctx)
+
+	writeLines(t, s, 0, 8)
+	_ = s.Flush(ctx)
+	c.status.Store(http.StatusOK)
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	st := s.Stats()
+	if st.Dropped == 0 || st.Sent+st.Dropped != 8 {
+		t.Errorf("Stats() = %+v, want the oldest lines dropped and the rest sent", st)
+	}
+	got := c.received()
+	if len(got) == 0 || got[len(got)-1] != `{"n":7}` {
+		t.Errorf("received %v, want the newest lines kept", got)
+	}
+}
+
+func TestSpoolTornLine(t *testing.T) {
+	ctx := context.Background()
+	c := newCollector(t)
+	dir := t.TempDir()
+	// A crash mid-write leaves a segment without its final newline.
+	seg := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, spoolExt))
+	if err := os.WriteFile(seg, []byte("{\"n\":0}\n{\"n\":1}\n{\"n\""), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := spoolSink(c.URL, dir, SpoolConfig{})
+	defer s.Close(ctx)
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.received(), []string{`{"n":0}`, `{"n":1}`}; !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,323 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SyslogConfig configures an RFC 5424 syslog sink.
+type SyslogConfig struct {
+	Addr    string      // collector host:port; "" disables
+	Network string      // "udp" (default), "tcp" or "tls"; stream transports use octet counting
+	TLS     *tls.Config // for "tls"; nil verifies against system roots
+
+	Facility int    // default 1 (user-level); 16-23 are local0-local7
+	AppName  string // default the executable name
+	Hostname string // default os.Hostname
+	SDID     string // SD-ID of the fields element (default "fields@32473")
+
+	Timeout time.Duration // dial and write timeout (default 5s)
+	Buffer  int           // lines buffered before dropping (default 1024)
+}
+
+// SyslogSink sends each log line as one RFC 5424 message: lvl maps to the
+// severity, component to MSGID, msg to MSG and every other field to a
+// PARAM of one SD-ELEMENT. Like HTTPSink it never blocks the caller.
+type SyslogSink struct {
+	cfg SyslogConfig
+	ch  chan syslogItem
+
+	conn net.Conn // used by loop only
+
+	mu     sync.RWMutex // guards closed against sends on a closed ch
+	closed bool
+	done   chan struct{}
+
+	sent, dropped, failed atomic.Uint64
+}
+
+// syslogItem is a line to send, or (line == nil) a flush marker.
+type syslogItem struct {
+	line  []byte
+	flush chan struct{}
+}
+
+func NewSyslogSink(cfg SyslogConfig) (*SyslogSink, error) {
+	cfg.Network = firstNonEmpty(cfg.Network, "udp")
+	switch cfg.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("logger: syslog: unknown network %q", cfg.Network)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("logger: syslog: %w", err)
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = 1
+	}
+	if cfg.Facility < 0 || cfg.Facility > 23 {
+		return nil, fmt.Errorf("logger: syslog: facility %d out of range", cfg.Facility)
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = filepath.Base(os.Args[0])
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	cfg.SDID = firstNonEmpty(cfg.SDID, "fields@32473")
+	cfg.Timeout = firstNonZero(cfg.Timeout, 5*time.Second)
+	cfg.Buffer = firstNonZeroInt(cfg.Buffer, 1024)
+
+	s := &SyslogSink{cfg: cfg, ch: make(chan syslogItem, cfg.Buffer), done: make(chan struct{})}
+	go s.loop()
+	return s, nil
+}
+
+func (s *SyslogSink) String() string { return "syslog" }
+
+func (s *SyslogSink) Write(p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return len(p), nil
+	}
+	cp := make([]byte, len(p))
+	copy(cp, p)
+	select {
+	case s.ch <- syslogItem{line: cp}:
+	default:
+		s.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Flush waits until every line written so far has been sent (or given up on).
+func (s *SyslogSink) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil
+	}
+	select {
+	case s.ch <- syslogItem{flush: done}:
+		s.mu.RUnlock()
+	case <-ctx.Done():
+		s.mu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes and closes the connection. Later writes are dropped.
+func (s *SyslogSink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+// Stats reports sent, dropped and failed lines.
+func (s *SyslogSink) Stats() SinkStats {
+	return SinkStats{Sent: s.sent.Load(), Dropped: s.dropped.Load(), Failed: s.failed.Load()}
+}
+
+func (s *SyslogSink) loop() {
+	defer close(s.done)
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+	for it := range s.ch {
+		if it.flush != nil {
+			close(it.flush)
+			continue
+		}
+		if err := s.send(s.format(it.line, time.Now())); err != nil {
+			s.failed.Add(1)
+		} else {
+			s.sent.Add(1)
+		}
+	}
+}
+
+// send writes one message, redialing once if the connection broke.
+func (s *SyslogSink) send(msg []byte) error {
+	if s.cfg.Network != "udp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...) // RFC 6587 octet counting
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				return err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: s.cfg.Timeout}
+	if s.cfg.Network == "tls" {
+		cfg := s.cfg.TLS
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(s.cfg.Addr)
+			cfg = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		return tls.DialWithDialer(d, "tcp", s.cfg.Addr, cfg)
+	}
+	return d.Dial(s.cfg.Network, s.cfg.Addr)
+}
+
+// format renders a JSON log line as
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID k="v" ...] MSG.
+// TIMESTAMP is the line's own; now is used if it has none we can parse.
+func (s *SyslogSink) format(line []byte, now time.Time) []byte {
+	sev, msgID, msg, ts := 6, "-", "", now
+	var sd bytes.Buffer
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err == nil && tok == json.Delim('{') {
+		for dec.More() {
+			kt, err := dec.Token()
+			if err != nil {
+				break
+			}
+			key, _ := kt.(string)
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				break
+			}
+			var str string
+			isStr := json.Unmarshal(raw, &str) == nil
+			switch {
+			case key == zerolog.LevelFieldName && isStr:
+				sev = syslogSeverity(str)
+			case key == zerolog.MessageFieldName && isStr:
+				msg = str
+			case key == "component" && isStr && str != "":
+				msgID = syslogToken(str, 32)
+			case key == zerolog.TimestampFieldName && isStr && parseTime(str, &ts):
+				// Goes in the header; an unparseable one stays in SD.
+			default:
+				if !isStr {
+					str = string(raw)
+				}
+				fmt.Fprintf(&sd, ` %s="%s"`, syslogToken(key, 32), sdEscape(str))
+			}
+		}
+	} else {
+		msg = strings.TrimRight(string(line), "\n")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ",
+		s.cfg.Facility*8+sev,
+		ts.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogToken(s.cfg.Hostname, 255),
+		syslogToken(s.cfg.AppName, 48),
+		os.Getpid(),
+		msgID,
+	)
+	if sd.Len() > 0 {
+		b.WriteString("[" + s.cfg.SDID)
+		b.Write(sd.Bytes())
+		b.WriteString("]")
+	} else {
+		b.WriteString("-")
+	}
+	if msg != "" {
+		b.WriteString(" " + msg)
+	}
+	return b.Bytes()
+}
+
+// syslogSeverity maps zerolog levels to RFC 5424 severities.
+func syslogSeverity(lvl string) int {
+	switch lvl {
+	case zerolog.LevelPanicValue:
+		return 1 // alert
+	case zerolog.LevelFatalValue:
+		return 2 // critical
+	case zerolog.LevelErrorValue:
+		return 3
+	case zerolog.LevelWarnValue:
+		return 4
+	case zerolog.LevelInfoValue:
+		return 6
+	case zerolog.LevelDebugValue, zerolog.LevelTraceValue:
+		return 7
+	}
+	return 5 // notice
+}
+
+// syslogToken keeps the printable US-ASCII a header field or PARAM-NAME
+// allows ('=', ' ', ']' and '"' are replaced), capped at max bytes.
+func syslogToken(s string, max int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < max; i++ {
+		c := s[i]
+		switch {
+		case c < 33 || c > 126 || c == '=' || c == ']' || c == '"':
+			b = append(b, '_')
+		default:
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// sdEscape escapes '"', '\' and ']' in a PARAM-VALUE.
+func sdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// parseTime parses a zerolog timestamp into t.
+func parseTime(s string, t *time.Time) bool {
+	v, err := time.Parse(zerolog.TimeFieldFormat, s)
+	if err != nil {
+		return false
+	}
+	*t = v
+	return true
+}
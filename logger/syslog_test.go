@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	setGlobals()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{
+			name: "info",
+			line: `{"lvl":"info","ts":"2024-04-30T10:20:30+02:00","msg":"started"}`,
+			want: `<14>1 2024-04-30T08:20:30.000000Z host app ` + pid + ` - - started`,
+		},
+		{
+			name: "error with fields and component",
+			line: `{"lvl":"error","component":"pgxkit","ts":"2024-04-30T08:20:30Z","n":3,"ok":true,"msg":"query failed"}`,
+			want: `<11>1 2024-04-30T08:20:30.000000Z host app ` + pid + ` pgxkit [fields@32473 n="3" ok="true"] query failed`,
+		},
+		{
+			name: "missing timestamp falls back to now",
+			line: `{"lvl":"warn","msg":"m"}`,
+			want: `<12>1 2024-05-01T12:00:00.000000Z host app ` + pid + ` - - m`,
+		},
+		{
+			name: "unparseable timestamp kept as a param",
+			line: `{"lvl":"debug","ts":"yesterday","msg":"m"}`,
+			want: `<15>1 2024-05-01T12:00:00.000000Z host app ` + pid + ` - [fields@32473 ts="yesterday"] m`,
+		},
+		{
+			name: "escaping",
+			line: `{"lvl":"info","bad key=":"a\"b]c\\d","msg":"m"}`,
+			want: `<14>1 2024-05-01T12:00:00.000000Z host app ` + pid + ` - [fields@32473 bad_key_="a\"b\]c\\d"] m`,
+		},
+		{
+			name: "unknown level is notice",
+			line: `{"lvl":"custom","msg":"m"}`,
+			want: `<13>1 2024-05-01T12:00:00.000000Z host app ` + pid + ` - - m`,
+		},
+		{
+			name: "not json is info",
+			line: "plain text\n",
+			want: `<14>1 2024-05-01T12:00:00.000000Z host app ` + pid + ` - - plain text`,
+		},
+	}
+	s := &SyslogSink{cfg: SyslogConfig{Facility: 1, AppName: "app", Hostname: "host", SDID: "fields@32473"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(s.format([]byte(tt.line), now)); got != tt.want {
+				t.Errorf("format() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogToken(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{in: "orders", max: 48, want: "orders"},
+		{in: "", max: 48, want: "-"},
+		{in: "a b=c\"d]é", max: 48, want: "a_b_c_d___"},
+		{in: "abcdef", max: 3, want: "abc"},
+	}
+	for _, tt := range tests {
+		if got := syslogToken(tt.in, tt.max); got != tt.want {
+			t.Errorf("syslogToken(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+func newTestSyslogSink(t *testing.T, network, addr string) *SyslogSink {
+	t.Helper()
+	s, err := NewSyslogSink(SyslogConfig{Addr: addr, Network: network, AppName: "app", Hostname: "host", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func syslogLines(n int) []string {
+	var out []string
+	for i := range n {
+		out = append(out, fmt.Sprintf(`{"lvl":"info","msg":"message %d"}`, i))
+	}
+	// A message longer than one read and one with a newline inside.
+	out = append(out, `{"lvl":"info","msg":"`+strings.Repeat("x", 5000)+`"}`, `{"lvl":"info","msg":"two\nlines"}`)
+	return out
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	s := newTestSyslogSink(t, "udp", pc.LocalAddr().String())
+	defer s.Close(context.Background())
+
+	lines := syslogLines(3)
+	for _, l := range lines {
+		_, _ = s.Write([]byte(l + "\n"))
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// One datagram per message, no framing.
+	buf := make([]byte, 64<<10)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i, l := range lines {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		checkSyslogMessage(t, string(buf[:n]), l)
+	}
+	if st := s.Stats(); st.Sent != uint64(len(lines)) {
+		t.Errorf("Stats() = %+v", st)
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	s := newTestSyslogSink(t, "tcp", ln.Addr().String())
+
+	lines := syslogLines(3)
+	for _, l := range lines {
+		_, _ = s.Write([]byte(l + "\n"))
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	// RFC 6587 octet counting: MSG-LEN SP SYSLOG-MSG, back to back.
+	for i, l := range lines {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+		if err != nil {
+			t.Fatalf("message %d: bad length %q", i, size)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		checkSyslogMessage(t, string(msg), l)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("trailing data after the last message: %v", err)
+	}
+}
+
+func TestSyslogSinkConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SyslogConfig
+	}{
+		{name: "bad network", cfg: SyslogConfig{Addr: "127.0.0.1:514", Network: "unix"}},
+		{name: "no port", cfg: SyslogConfig{Addr: "localhost"}},
+		{name: "facility", cfg: SyslogConfig{Addr: "127.0.0.1:514", Facility: 24}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSyslogSink(tt.cfg); err == nil {
+				t.Error("NewSyslogSink() = nil error")
+			}
+		})
+	}
+}
+
+// checkSyslogMessage checks the header of msg and that it carries line's msg.
+func checkSyslogMessage(t *testing.T, msg, line string) {
+	t.Helper()
+	parts := strings.SplitN(msg, " ", 8)
+	if len(parts) != 8 {
+		t.Fatalf("malformed message %q", msg)
+	}
+	if parts[0] != "<14>1" || parts[2] != "host" || parts[3] != "app" || parts[4] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("header = %q", strings.Join(parts[:7], " "))
+	}
+	if _, err := time.Parse(time.RFC3339Nano, parts[1]); err != nil {
+		t.Errorf("timestamp %q: %v", parts[1], err)
+	}
+	want := line[strings.Index(line, `"msg":"`)+7 : len(line)-2]
+	if got := strings.ReplaceAll(parts[7], "\n", `\n`); got != want {
+		t.Errorf("MSG = %.40q, want %.40q", got, want)
+	}
+}
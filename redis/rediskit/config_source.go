@@ -0,0 +1,94 @@
+package rediskit
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/ranakdinesh/spur/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// ConfigSource serves config values from a Redis hash whose fields are env
+// names, so settings shared by every service (downstream URLs, tenant limits)
+// live in one place:
+//
+//	rdb, _ := rediskit.NewClient(ctx, rediskit.Options{Addr: "redis:6379"})
+//	src := rediskit.NewConfigSource(rdb, "config:shared")
+//	config.LoadWithOptions(&cfg, config.Options{Sources: []config.Source{src}})
+//
+// Passed to config.Watch, it polls the hash and triggers a reload on change.
+type ConfigSource struct {
+	rdb *redis.Client
+	key string
+
+	// Poll is how often Watch re-reads the hash (default 10s).
+	Poll time.Duration
+
+	mu      sync.Mutex
+	snap    map[string]string
+	fetched time.Time
+}
+
+var _ config.WatchableSource = (*ConfigSource)(nil)
+
+// snapshotTTL lets one Load (many lookups in a row) share a single HGETALL.
+const snapshotTTL = time.Second
+
+func NewConfigSource(rdb *redis.Client, key string) *ConfigSource {
+	return &ConfigSource{rdb: rdb, key: key, Poll: 10 * time.Second}
+}
+
+func (s *ConfigSource) String() string { return "redis(" + s.key + ")" }
+
+// Lookup returns field key of the hash.
+func (s *ConfigSource) Lookup(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snap == nil || time.Since(s.fetched) > snapshotTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		m, err := s.rdb.HGetAll(ctx, s.key).Result()
+		if err != nil {
+			return "", false, err
+		}
+		s.snap, s.fetched = m, time.Now()
+	}
+	v, ok := s.snap[key]
+	return v, ok, nil
+}
+
+// Watch polls the hash every Poll and calls changed when its contents differ
+// from the previous poll. Transient Redis errors are retried on the next tick.
+func (s *ConfigSource) Watch(ctx context.Context, changed func()) error {
+	poll := s.Poll
+	if poll <= 0 {
+		poll = 10 * time.Second
+	}
+	t := time.NewTicker(poll)
+	defer t.Stop()
+
+	// Start from what the last load saw, so a change made since then counts.
+	s.mu.Lock()
+	last := s.snap
+	s.mu.Unlock()
+	for {
+		m, err := s.rdb.HGetAll(ctx, s.key).Result()
+		if err == nil {
+			if last != nil && !maps.Equal(last, m) {
+				// Hand the reload this poll rather than a cached snapshot.
+				s.mu.Lock()
+				s.snap, s.fetched = m, time.Now()
+				s.mu.Unlock()
+				changed()
+			}
+			last = m
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package rediskit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ranakdinesh/spur/config"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+// unsetenv unsets key for the test, restoring it afterwards.
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestConfigSourceLookup(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.HSet("config:shared", "DOWNSTREAM_URL", "http://orders", "EMPTY", "")
+	src := NewConfigSource(rdb, "config:shared")
+
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "DOWNSTREAM_URL", want: "http://orders", wantOK: true},
+		{key: "EMPTY", want: "", wantOK: true},
+		{key: "MISSING", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok, err := src.Lookup(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+type sharedConfig struct {
+	DownstreamURL string `env:"DOWNSTREAM_URL" default:"http://localhost"`
+	Timeout       time.Duration
+	Limit         int `default:"10"`
+}
+
+func TestConfigSourceLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		hash       map[string]string
+		env        map[string]string
+		wantURL    string
+		wantLimit  int
+		wantOrigin map[string]string // field -> source
+	}{
+		{
+			name:       "hash over defaults",
+			hash:       map[string]string{"DOWNSTREAM_URL": "http://orders", "TIMEOUT": "3s"},
+			wantURL:    "http://orders",
+			wantLimit:  10,
+			wantOrigin: map[string]string{"DownstreamURL": "redis(config:shared)", "Timeout": "redis(config:shared)", "Limit": "default"},
+		},
+		{
+			name:       "env over hash",
+			hash:       map[string]string{"DOWNSTREAM_URL": "http://orders", "LIMIT": "5"},
+			env:        map[string]string{"LIMIT": "7"},
+			wantURL:    "http://orders",
+			wantLimit:  7,
+			wantOrigin: map[string]string{"DownstreamURL": "redis(config:shared)", "Limit": "env"},
+		},
+		{
+			name:       "empty hash",
+			wantURL:    "http://localhost",
+			wantLimit:  10,
+			wantOrigin: map[string]string{"DownstreamURL": "default", "Limit": "default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, rdb := newTestRedis(t)
+			for k, v := range tt.hash {
+				mr.HSet("config:shared", k, v)
+			}
+			t.Setenv("CONFIG_FILE", "")
+			for _, k := range []string{"DOWNSTREAM_URL", "TIMEOUT", "LIMIT"} {
+				if v, ok := tt.env[k]; ok {
+					t.Setenv(k, v)
+				} else {
+					unsetenv(t, k)
+				}
+			}
+
+			var cfg sharedConfig
+			src := NewConfigSource(rdb, "config:shared")
+			origins, err := config.LoadOrigins(&cfg, config.Options{Dir: t.TempDir(), Sources: []config.Source{src}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DownstreamURL != tt.wantURL || cfg.Limit != tt.wantLimit {
+				t.Errorf("got %+v, want DownstreamURL=%q Limit=%d", cfg, tt.wantURL, tt.wantLimit)
+			}
+			for _, o := range origins {
+				if want, ok := tt.wantOrigin[o.Field]; ok && o.Source != want {
+					t.Errorf("%s source = %q, want %q", o.Field, o.Source, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigSourceUnavailable(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.Close()
+	t.Setenv("CONFIG_FILE", "")
+
+	var cfg sharedConfig
+	src := NewConfigSource(rdb, "config:shared")
+	err := config.LoadWithOptions(&cfg, config.Options{Dir: t.TempDir(), Sources: []config.Source{src}})
+	var cerr *config.Error
+	if !errors.As(err, &cerr) || len(cerr.Fields) != 1 || cerr.Fields[0].Field != "redis(config:shared)" {
+		t.Fatalf("err = %v, want one field error for the source", err)
+	}
+}
+
+func TestConfigSourceWatch(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.HSet("config:shared", "LIMIT", "5")
+	t.Setenv("CONFIG_FILE", "")
+	unsetenv(t, "LIMIT")
+
+	src := NewConfigSource(rdb, "config:shared")
+	src.Poll = 10 * time.Millisecond
+	w, err := config.Watch[sharedConfig](t.Context(), config.WatchOptions{
+		Options:  config.Options{Dir: t.TempDir(), Sources: []config.Source{src}},
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Load().Limit; got != 5 {
+		t.Fatalf("Limit = %d, want 5", got)
+	}
+	changed := make(chan int, 1)
+	w.OnChange(func(_, new *sharedConfig) {
+		select {
+		case changed <- new.Limit:
+		default:
+		}
+	})
+
+	mr.HSet("config:shared", "LIMIT", "9")
+	select {
+	case got := <-changed:
+		if got != 9 {
+			t.Errorf("Limit = %d, want 9", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hash change not picked up")
+	}
+}
+
+func TestConfigSourceWatchStops(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	src := NewConfigSource(rdb, "config:shared")
+	src.Poll = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- src.Watch(ctx, func() {}) }()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Watch() = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch didn't return after cancel")
+	}
+}
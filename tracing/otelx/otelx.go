@@ -20,15 +20,7 @@ type Options struct {
 }
 
 func Start(ctx context.Context, opt Options) (func(context.Context) error, error) {
-	if opt.ServiceName == "" {
-		opt.ServiceName = os.Getenv("OTEL_SERVICE_NAME")
-	}
-	if opt.Environment == "" {
-		opt.Environment = os.Getenv("APP_ENV")
-	}
-	if opt.OTLPEndpoint == "" {
-		opt.OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	}
+	opt = withEnv(opt)
 
 	exp, err := otlptracehttp.New(ctx,
 		otlptracehttp.WithEndpointURL(opt.OTLPEndpoint), // accepts http(s)://host:4318
@@ -38,18 +30,9 @@ func Start(ctx context.Context, opt Options) (func(context.Context) error, error
 	}
 
 	sampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleDefault(opt)))
-	res, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(opt.ServiceName),
-			semconv.DeploymentEnvironmentKey.String(opt.Environment),
-		),
-	)
-
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithSampler(sampler),
-		sdktrace.WithResource(res),
+		sdktrace.WithResource(newResource(opt)),
 		sdktrace.WithBatcher(exp,
 			sdktrace.WithBatchTimeout(2*time.Second),
 			sdktrace.WithMaxExportBatchSize(512),
@@ -60,6 +43,42 @@ func Start(ctx context.Context, opt Options) (func(context.Context) error, error
 	return tp.Shutdown, nil
 }
 
+// ResourceAttributes returns the resource attributes Start puts on traces
+// (service name, environment, SDK info), e.g. for logger.Options.OTLPResource
+// so logs and traces line up in the collector.
+func ResourceAttributes(opt Options) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range newResource(withEnv(opt)).Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	return attrs
+}
+
+func withEnv(opt Options) Options {
+	if opt.ServiceName == "" {
+		opt.ServiceName = os.Getenv("OTEL_SERVICE_NAME")
+	}
+	if opt.Environment == "" {
+		opt.Environment = os.Getenv("APP_ENV")
+	}
+	if opt.OTLPEndpoint == "" {
+		opt.OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	return opt
+}
+
+func newResource(opt Options) *resource.Resource {
+	res, _ := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(opt.ServiceName),
+			semconv.DeploymentEnvironmentKey.String(opt.Environment),
+		),
+	)
+	return res
+}
+
 func sampleDefault(opt Options) float64 {
 	if opt.SampleRatio > 0 {
 		return opt.SampleRatio